@@ -2,12 +2,4 @@ module wspr
 
 go 1.22
 
-require (
-	github.com/chiefMarlin/tinygo-drivers v0.0.0-20240401153109-2e22e3b00732
-	github.com/tinygo-org/pio v0.0.0-20241219082822-57ca4e0dc776
-)
-
-require (
-	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
-	tinygo.org/x/drivers v0.26.0 // indirect
-)
+require github.com/tinygo-org/pio v0.0.0-20241219082822-57ca4e0dc776
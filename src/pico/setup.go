@@ -19,7 +19,6 @@ package pico
 import (
 	"device/rp"
 	"fmt"
-	"github.com/chiefMarlin/tinygo-drivers/si5351"
 	pio "github.com/tinygo-org/pio/rp2-pio"
 	"machine"
 	"runtime/interrupt"
@@ -27,6 +26,8 @@ import (
 	"time"
 	"unsafe"
 	"wspr/src/machine_x"
+	"wspr/src/protocol"
+	"wspr/src/si5351"
 	"wspr/src/support"
 )
 
@@ -450,28 +451,27 @@ func setupClock() {
 		panic("Unable to connect to SI5351 device")
 	}
 
-	err = clockgen.Configure()
+	err = clockgen.Init()
 	if err != nil {
 		panic("Unable to configure device")
 	}
 
-	// Now configure the PLLs for 750MHz = 24 * 25MHz
-	pllMul := 30
-	err = clockgen.ConfigurePLL(si5351.PLL_A, uint8(pllMul), 0, 1)
+	// Clock 0 at 28.85MHz = 750MHz / 26 with PLL A at 750MHz = 30 * 25MHz
+	config, err := support.New(25*protocol.Megahertz, 750*protocol.Megahertz, protocol.Megahertz.MulDiv(25*30, 26))
 	if err != nil {
-		panic("Unable to configure PLL")
+		panic(fmt.Errorf("unable to compute clock settings %v", err))
 	}
-	fmt.Printf("PLL A frequency: %.1f", float64(pllMul)*25.0)
-
-	// Now configure the clock output for 28.85MHz = 750MHz / 26
-	div := uint32(26)
-	err = clockgen.ConfigureMultisynth(0, si5351.PLL_A, div, 0, 1)
+	err = clockgen.Apply(config, support.PLLA, 0)
 	if err != nil {
 		panic(fmt.Errorf("unable to configure output %v", err))
 	}
-	fmt.Printf("Clock 0: %.3f kHz\n", 25e6*float64(pllMul)/float64(div)/1e3)
+	err = clockgen.ResetPLL(support.PLLA)
+	if err != nil {
+		panic("Unable to reset PLL")
+	}
+	fmt.Printf("Clock 0: %v\n", config.Frequency())
 
-	err = clockgen.EnableOutputs()
+	err = clockgen.EnableOutput(0, true)
 	if err != nil {
 		panic("Unable to enable outputs")
 	}
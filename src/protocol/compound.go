@@ -0,0 +1,194 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+This file contains code that implements WSPR Type 2 messages. These carry a
+compound call sign such as PJ4/K1ABC or K1ABC/P and the power, but no locator.
+The prefix or suffix is squeezed into the 15 bits normally used by the locator
+with one more bit hidden in the power field by making it a value that a Type 1
+message can't have.
+*/
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// extensions at or above this value are suffixes rather than prefixes
+	suffixBase = 60000
+)
+
+/*
+WsprMessageType2 encodes a compound call sign and power as a Type 2 message
+in the same form as WsprMessage.
+*/
+func WsprMessageType2(callsign string, power int) ([]int8, error) {
+	bits, err := PackType2(callsign, power)
+	if err != nil {
+		return nil, err
+	}
+	return channelSymbols(bits), nil
+}
+
+/*
+PackType2 encodes a compound call sign and power into bits 55...6 in the same
+layout as PackBits. The base call goes in the callsign field, the low 15 bits
+of the prefix or suffix go in the locator field and the high bit of the
+prefix or suffix is added to the power along with an offset of 1 so that the
+power no longer ends in 0, 3 or 7.
+*/
+func PackType2(callsign string, power int) (uint64, error) {
+	c, ext, err := CompoundCallSign(callsign)
+	if err != nil {
+		return 0, err
+	}
+	if !ValidPower(power) {
+		return 0, errIllegalPower(power)
+	}
+	ntype := uint64(power) + 1 + ext>>15
+	return (c << 28) + ((ext & 0x7fff) << 13) + (Power(int(ntype)) << 6), nil
+}
+
+/*
+CompoundCallSign splits a call sign with a prefix (PJ4/K1ABC) or suffix
+(K1ABC/P) into the encoded base call and a 16-bit encoding of the prefix or
+suffix.
+
+Prefixes can be 1 to 3 letters or digits. Suffixes can be a single letter or
+digit or two digits. These limits are imposed by the WSPR protocol, anything
+else results in an error.
+*/
+func CompoundCallSign(callsign string) (call uint64, extension uint64, err error) {
+	parts := strings.Split(callsign, "/")
+	if len(parts) != 2 {
+		return 0, 0, newFieldError("callsign", callsign, -1, "compound call sign must have exactly one '/'")
+	}
+	base, ext := parts[0], parts[1]
+	offset := 0
+	if len(ext) <= 2 {
+		// suffix
+		extension, err = encodeSuffix(ext)
+	} else {
+		// prefix
+		base, ext = ext, base
+		offset = len(ext) + 1
+		extension, err = encodePrefix(ext)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	call, err = CallSign(base)
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		// report the problem relative to the whole call sign
+		fe.Value = callsign
+		if fe.Position >= 0 {
+			fe.Position += offset
+		}
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return call, extension, nil
+}
+
+func encodePrefix(prefix string) (uint64, error) {
+	if len(prefix) < 1 || len(prefix) > 3 {
+		return 0, newFieldError("prefix", prefix, -1, "must have 1 to 3 characters")
+	}
+	var encoded uint64
+	var err error
+	for i := len(prefix); i < 3; i++ {
+		encoded, err = encodeChar(' ', ALPHA|DIGIT|SPACE, encoded)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for i := 0; i < len(prefix); i++ {
+		if prefix[i] == ' ' {
+			return 0, newFieldError("prefix", prefix, i, "invalid character")
+		}
+		encoded, err = encodeChar(prefix[i], ALPHA|DIGIT|SPACE, encoded)
+		if err != nil {
+			return 0, newFieldError("prefix", prefix, i, "invalid character")
+		}
+	}
+	return encoded, nil
+}
+
+func encodeSuffix(suffix string) (uint64, error) {
+	switch len(suffix) {
+	case 1:
+		encoded, err := encodeChar(suffix[0], ALPHA|DIGIT, 0)
+		if err != nil {
+			return 0, newFieldError("suffix", suffix, 0, "invalid character")
+		}
+		return suffixBase + encoded, nil
+	case 2:
+		for i := 0; i < 2; i++ {
+			if !isdigit(suffix[i]) || suffix[0] == '0' {
+				return 0, newFieldError("suffix", suffix, i, "two character suffix must be a number from 10 to 99")
+			}
+		}
+		return suffixBase + 26 + 10*uint64(suffix[0]-'0') + uint64(suffix[1]-'0'), nil
+	default:
+		return 0, newFieldError("suffix", suffix, -1, "must have 1 or 2 characters")
+	}
+}
+
+/*
+unpackType2 completes the decoding of a Type 2 message given the already
+decoded base call.
+*/
+func unpackType2(base string, bits uint64) (Message, error) {
+	ntype := UnpackPower((bits >> 6) & 0x7f)
+	nadd := ntype % 10
+	if nadd > 7 {
+		nadd -= 7
+	} else if nadd > 3 {
+		nadd -= 3
+	}
+	m := Message{Type: Type2, Power: ntype - nadd}
+	ext := (bits>>13)&0x7fff + uint64(nadd-1)<<15
+	if ext < suffixBase {
+		prefix := [3]byte{}
+		for i := 2; i >= 0; i-- {
+			prefix[i] = callChars[ext%37]
+			ext /= 37
+		}
+		p := strings.TrimLeft(string(prefix[:]), " ")
+		if p == "" || strings.Contains(p, " ") {
+			return Message{}, newFieldError("prefix", string(prefix[:]), -1, "encoded value is invalid")
+		}
+		m.CallSign = p + "/" + base
+		return m, nil
+	}
+	ext -= suffixBase
+	switch {
+	case ext < 36:
+		m.CallSign = base + "/" + callChars[ext:ext+1]
+	case ext < 126:
+		m.CallSign = fmt.Sprintf("%s/%d", base, ext-26)
+	default:
+		return Message{}, newFieldError("suffix", strconv.FormatUint(ext, 10), -1, "encoded value is invalid")
+	}
+	return m, nil
+}
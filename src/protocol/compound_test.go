@@ -0,0 +1,103 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import "testing"
+
+func TestCompoundCallSign(t *testing.T) {
+	k1abc, _ := CallSign("K1ABC")
+	for _, test := range []struct {
+		callsign  string
+		extension uint64
+		wantErr   bool
+	}{
+		// PJ4 = (25*37 + 19)*37 + 4
+		{"PJ4/K1ABC", 34932, false},
+		// F with two leading spaces
+		{"F/K1ABC", (36*37+36)*37 + 15, false},
+		{"VE/K1ABC", 36*37*37 + 31*37 + 14, false},
+		{"K1ABC/P", 60000 + 25, false},
+		{"K1ABC/7", 60000 + 7, false},
+		{"K1ABC/12", 60000 + 26 + 12, false},
+
+		{"K1ABC", 0, true},
+		{"A/B/K1ABC", 0, true},
+		{"PJ4X/K1ABC", 0, true},
+		{"P-4/K1ABC", 0, true},
+		{"/K1ABC", 0, true},
+		{"K1ABC/", 0, true},
+		{"K1ABC/MM", 0, true},
+		{"K1ABC/07", 0, true},
+		{"K1ABC/-", 0, true},
+		{"PJ4/K1ABCDE", 0, true},
+	} {
+		t.Run(test.callsign, func(t *testing.T) {
+			call, ext, err := CompoundCallSign(test.callsign)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("CompoundCallSign(%q) error = %v, wantErr %v", test.callsign, err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if call != k1abc {
+				t.Errorf("CompoundCallSign(%q) call = %d, want %d", test.callsign, call, k1abc)
+			}
+			if ext != test.extension {
+				t.Errorf("CompoundCallSign(%q) extension = %d, want %d", test.callsign, ext, test.extension)
+			}
+		})
+	}
+}
+
+func TestPackType2(t *testing.T) {
+	k1abc, _ := CallSign("K1ABC")
+	// the high bit of the prefix moves into the power which becomes 37 + 1 + 1
+	want := k1abc<<28 + (34932-32768)<<13 + (0x40+39)<<6
+	got, err := PackType2("PJ4/K1ABC", 37)
+	if err != nil {
+		t.Fatalf("PackType2() unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("PackType2() = %x, want %x", got, want)
+	}
+	for _, power := range []int{-3, 5, 61} {
+		if _, err := PackType2("PJ4/K1ABC", power); err == nil {
+			t.Errorf("PackType2() expected error for power %d", power)
+		}
+	}
+}
+
+func TestType2RoundTrip(t *testing.T) {
+	for _, callsign := range []string{
+		"PJ4/K1ABC", "F/K1ABC", "VE/KA1ABC", "ZZZ/W8ZLW", "3D2/E51ABC", "0/K1A",
+		"K1ABC/P", "K1ABC/0", "K1ABC/Z", "K1ABC/10", "HB9EGM/99",
+	} {
+		for _, power := range []int{0, 3, 7, 37, 60} {
+			symbols, err := WsprMessageType2(callsign, power)
+			if err != nil {
+				t.Fatalf("WsprMessageType2(%q, %d) unexpected error: %v", callsign, power, err)
+			}
+			call, loc, p, err := Decode(symbols)
+			if err != nil {
+				t.Fatalf("Decode() unexpected error for %q: %v", callsign, err)
+			}
+			if call != callsign || loc != "" || p != power {
+				t.Errorf("Decode() got %q %q %d, want %q %d", call, loc, p, callsign, power)
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+This file contains code that turns WSPR channel symbols back into a message. It
+is the inverse of WsprMessage.
+*/
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// generator polynomials for the K=32, r=1/2 convolutional code
+	poly1 = 0xF2D05351
+	poly2 = 0xE4613C47
+
+	// number of bits that go through the encoder including the zero tail
+	codedBits = 81
+
+	// Fano metrics for hard decisions in tenths of a bit assuming a symbol
+	// error rate of about 5%. These are log2(2(1-p)) - 1/2 and log2(2p) - 1/2
+	fanoMatch    = 4
+	fanoMismatch = -38
+
+	// threshold step and cycle limit (per bit) for the Fano decoder
+	fanoDelta     = 40
+	fanoMaxCycles = 10_000
+)
+
+/*
+Decode converts 162 channel symbols as produced by WsprMessage back into a
+callsign, locator and power. The sync vector is stripped, the remaining bits
+are de-interleaved and then run through a sequential (Fano) decoder.
+
+An error is returned if the symbols are malformed or if the decoder cannot
+find a plausible message.
+*/
+func Decode(symbols []int8) (callsign, locator string, power int, err error) {
+	bits, err := DecodeBits(symbols)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return UnpackBits(bits)
+}
+
+/*
+DecodeBits converts 162 channel symbols into the 50 message bits in the same
+layout returned by PackBits. This is the inverse of everything in WsprMessage
+except the packing of the callsign, locator and power.
+*/
+func DecodeBits(symbols []int8) (uint64, error) {
+	if len(symbols) != len(sync) {
+		return 0, fmt.Errorf("wrong number of symbols, got %d, want %d", len(symbols), len(sync))
+	}
+	data := make([]int8, len(symbols))
+	for i, s := range symbols {
+		if s < 0 || s > 3 {
+			return 0, fmt.Errorf("invalid symbol %d at position %d", s, i)
+		}
+		data[i] = s >> 1
+	}
+	return fano(deinterleave(data))
+}
+
+/*
+deinterleave undoes interleave by reading each value back from the bit-reversed
+position that interleave wrote it to.
+*/
+func deinterleave(message []int8) []int8 {
+	n := len(message)
+	dest := make([]int8, len(message))
+	di := 0
+	for i := 0; i < 255; i++ {
+		ix := reverseByte(i)
+		if ix < n {
+			dest[di] = message[ix]
+			di++
+			if di >= len(message) {
+				return dest
+			}
+		}
+	}
+	return nil
+}
+
+// fanoNode is the decoder state at one bit position of the message
+type fanoNode struct {
+	encstate uint32 // encoder state with the bit for this node in the low bit
+	gamma    int    // cumulative path metric up to this node
+	metrics  [4]int // branch metrics for each possible pair of symbols
+	tm       [2]int // sorted metrics for the best and second best branch
+	i        int    // which of the two branches we are currently exploring
+}
+
+/*
+fano is a sequential decoder for the WSPR convolutional code following the
+classic algorithm by Phil Karn, KA9Q. The search moves forward along the best
+branch as long as the path metric stays above a running threshold, backs up to
+try the second best branch when it doesn't, and relaxes the threshold when
+there is nowhere else to go. Because both polynomials are odd, the symbols for
+the 1-branch are always the complement of those for the 0-branch.
+
+The input is 162 de-interleaved hard bits and the output is the 50 message bits
+in bits 55...6.
+*/
+func fano(symbols []int8) (uint64, error) {
+	nodes := make([]fanoNode, codedBits+1)
+	tail := codedBits - 31
+	for i := 0; i < codedBits; i++ {
+		m0 := bitMetric(0, symbols[2*i])
+		m1 := bitMetric(1, symbols[2*i])
+		n0 := bitMetric(0, symbols[2*i+1])
+		n1 := bitMetric(1, symbols[2*i+1])
+		nodes[i].metrics = [4]int{m0 + n0, m0 + n1, m1 + n0, m1 + n1}
+	}
+
+	// sort the branches at node k, the low bit of encstate picks the branch
+	branches := func(k int) {
+		np := &nodes[k]
+		lsym := encodeState(np.encstate)
+		if k >= tail {
+			// the tail is all zeros so the 1-branch is never taken
+			np.tm[0] = np.metrics[lsym]
+		} else {
+			m0 := np.metrics[lsym]
+			m1 := np.metrics[3^lsym]
+			if m0 > m1 {
+				np.tm[0], np.tm[1] = m0, m1
+			} else {
+				np.tm[0], np.tm[1] = m1, m0
+				np.encstate++
+			}
+		}
+		np.i = 0
+	}
+
+	k := 0
+	t := 0
+	branches(0)
+	done := false
+	for cycles := 0; cycles < fanoMaxCycles*codedBits; cycles++ {
+		np := &nodes[k]
+		ngamma := np.gamma + np.tm[np.i]
+		if ngamma >= t {
+			if np.gamma < t+fanoDelta {
+				// first visit to this node, tighten the threshold
+				for ngamma >= t+fanoDelta {
+					t += fanoDelta
+				}
+			}
+			nodes[k+1].gamma = ngamma
+			nodes[k+1].encstate = np.encstate << 1
+			k++
+			if k == codedBits {
+				done = true
+				break
+			}
+			branches(k)
+			continue
+		}
+		// threshold violated, look backwards
+		for {
+			if k == 0 || nodes[k-1].gamma < t {
+				// can't back up either, relax the threshold and try the best branch again
+				t -= fanoDelta
+				if nodes[k].i != 0 {
+					nodes[k].i = 0
+					nodes[k].encstate ^= 1
+				}
+				break
+			}
+			k--
+			if k < tail && nodes[k].i != 1 {
+				// try the second best branch
+				nodes[k].i++
+				nodes[k].encstate ^= 1
+				break
+			}
+		}
+	}
+	if !done {
+		return 0, errors.New("fano decoder did not converge")
+	}
+
+	var bits uint64
+	for i := 0; i < 50; i++ {
+		bits |= uint64(nodes[i].encstate&1) << (55 - i)
+	}
+	return bits, nil
+}
+
+// encodeState returns the two encoder output bits for the given state
+func encodeState(state uint32) int {
+	return parity32(state&poly1)<<1 | parity32(state&poly2)
+}
+
+func bitMetric(sent int, received int8) int {
+	if int(received) == sent {
+		return fanoMatch
+	}
+	return fanoMismatch
+}
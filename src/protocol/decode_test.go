@@ -0,0 +1,101 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	for _, test := range []struct {
+		callsign string
+		locator  string
+		power    int
+		packed   uint64
+	}{
+		{"K1ABC", "FN42", 37, 0xF7_0C_23_8B_0D_19_40},
+		{"HB9EGM", "JN36", 30, 0x75_1D_C4_F7_A0_17_80},
+		{"W8ZLW", "AA00", 3, 0xF9_72_F2_8F_BB_90_C0},
+		{"KA1ABC", "RR99", 60, 0},
+	} {
+		t.Run(test.callsign, func(t *testing.T) {
+			symbols, err := WsprMessage(test.callsign, test.locator, test.power)
+			if err != nil {
+				t.Fatalf("unexpected error encoding: %v", err)
+			}
+			bits, err := DecodeBits(symbols)
+			if err != nil {
+				t.Fatalf("unexpected error decoding: %v", err)
+			}
+			if test.packed != 0 && bits != test.packed {
+				t.Errorf("DecodeBits() got %x, want %x", bits, test.packed)
+			}
+			call, loc, power, err := Decode(symbols)
+			if err != nil {
+				t.Fatalf("unexpected error decoding: %v", err)
+			}
+			if call != test.callsign || loc != test.locator || power != test.power {
+				t.Errorf("Decode() got %q %q %d, want %q %q %d",
+					call, loc, power, test.callsign, test.locator, test.power)
+			}
+		})
+	}
+}
+
+func TestDecodeWithErrors(t *testing.T) {
+	symbols, err := WsprMessage("K1ABC", "FN42", 37)
+	if err != nil {
+		t.Fatalf("unexpected error encoding: %v", err)
+	}
+	corrupted := slices.Clone(symbols)
+	// flip the data bit of a handful of widely separated symbols
+	for _, i := range []int{3, 40, 77, 118, 150} {
+		corrupted[i] ^= 2
+	}
+	call, loc, power, err := Decode(corrupted)
+	if err != nil {
+		t.Fatalf("unexpected error decoding: %v", err)
+	}
+	if call != "K1ABC" || loc != "FN42" || power != 37 {
+		t.Errorf("Decode() got %q %q %d", call, loc, power)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	symbols, _ := WsprMessage("K1ABC", "FN42", 37)
+	if _, err := DecodeBits(symbols[1:]); err == nil {
+		t.Errorf("expected error for short message")
+	}
+	bad := slices.Clone(symbols)
+	bad[10] = 4
+	if _, err := DecodeBits(bad); err == nil {
+		t.Errorf("expected error for invalid symbol")
+	}
+}
+
+func TestDeinterleave(t *testing.T) {
+	message := make([]int8, 162)
+	for i := range message {
+		message[i] = int8(i % 7)
+	}
+	got := deinterleave(interleave(message))
+	if slices.Compare(got, message) != 0 {
+		t.Errorf("deinterleave does not invert interleave")
+		vector_diff(got, message)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+This file contains code for computing the distance and initial bearing between
+two positions or two Maidenhead locators. Both a spherical model (fast and
+always defined) and the WGS-84 ellipsoid (accurate to a millimetre or so) are
+supported.
+*/
+
+const (
+	// mean radius of the earth in km (IUGG)
+	earthRadius = 6371.0088
+
+	// WGS-84 semi-major axis in km and flattening
+	wgs84A = 6378.137
+	wgs84F = 1 / 298.257223563
+
+	KmPerMile = 1.609344
+)
+
+// Path describes the shortest route from one position to another
+type Path struct {
+	Km      float64 // distance in kilometres
+	Miles   float64 // distance in statute miles
+	Bearing float64 // initial bearing in degrees clockwise from true north
+}
+
+func newPath(km, bearing float64) Path {
+	bearing = math.Mod(bearing+360, 360)
+	return Path{Km: km, Miles: km / KmPerMile, Bearing: bearing}
+}
+
+/*
+SphericalPath computes the great circle distance and initial bearing from one
+position to another assuming that the earth is a sphere. The distance can be
+off by up to about 0.5% relative to the ellipsoidal result.
+*/
+func SphericalPath(from, to LatLong) Path {
+	phi1, phi2 := radians(from.Lat), radians(to.Lat)
+	dLambda := radians(to.Long - from.Long)
+	dPhi := phi2 - phi1
+
+	// haversine formula is well-conditioned for small distances
+	h := math.Pow(math.Sin(dPhi/2), 2) + math.Cos(phi1)*math.Cos(phi2)*math.Pow(math.Sin(dLambda/2), 2)
+	sigma := 2 * math.Atan2(math.Sqrt(h), math.Sqrt(1-h))
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return newPath(earthRadius*sigma, degrees(math.Atan2(y, x)))
+}
+
+/*
+EllipsoidalPath computes the geodesic distance and initial bearing from one
+position to another on the WGS-84 ellipsoid using Vincenty's inverse formula.
+
+An error is returned for nearly antipodal points where the iteration does not
+converge. SphericalPath can be used as a fallback in that case.
+*/
+func EllipsoidalPath(from, to LatLong) (Path, error) {
+	a := wgs84A
+	f := wgs84F
+	b := a * (1 - f)
+
+	L := radians(to.Long - from.Long)
+	u1 := math.Atan((1 - f) * math.Tan(radians(from.Lat)))
+	u2 := math.Atan((1 - f) * math.Tan(radians(to.Lat)))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	lambda := L
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, cos2Alpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		sinSigma = math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			// coincident points
+			return newPath(0, 0), nil
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cos2Alpha != 0 {
+			// not an equatorial line
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		c := f / 16 * cos2Alpha * (4 + f*(4-3*cos2Alpha))
+		previous := lambda
+		lambda = L + (1-c)*f*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-previous) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return Path{}, errors.New("ellipsoidal distance did not converge, points are nearly antipodal")
+	}
+
+	uSq := cos2Alpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	s := b * A * (sigma - deltaSigma)
+
+	bearing := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	return newPath(s, degrees(bearing)), nil
+}
+
+/*
+LocatorPath computes the distance and initial bearing between the centres of
+two Maidenhead locators. The WGS-84 ellipsoid is used unless the locators are
+nearly antipodal in which case the spherical result is returned.
+
+An error is returned if either locator is invalid.
+*/
+func LocatorPath(from, to string) (Path, error) {
+	a, _, _, err := MaidenheadBox(from)
+	if err != nil {
+		return Path{}, err
+	}
+	b, _, _, err := MaidenheadBox(to)
+	if err != nil {
+		return Path{}, err
+	}
+	p, err := EllipsoidalPath(a, b)
+	if err != nil {
+		return SphericalPath(a, b), nil
+	}
+	return p, nil
+}
+
+func radians(x float64) float64 {
+	return x * math.Pi / 180
+}
+
+func degrees(x float64) float64 {
+	return x * 180 / math.Pi
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"math"
+	"testing"
+)
+
+func dms(d, m, s float64) float64 {
+	if d < 0 {
+		return d - m/60 - s/3600
+	}
+	return d + m/60 + s/3600
+}
+
+func TestEllipsoidalPath(t *testing.T) {
+	// Vincenty's own test case, Flinders Peak to Buninyong
+	flinders := LatLong{dms(-37, 57, 3.72030), dms(144, 25, 29.52440)}
+	buninyong := LatLong{dms(-37, 39, 10.15610), dms(143, 55, 35.38390)}
+	p, err := EllipsoidalPath(flinders, buninyong)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(p.Km-54.972271) > 1e-6 {
+		t.Errorf("distance = %.6f km, want 54.972271", p.Km)
+	}
+	if math.Abs(p.Bearing-dms(306, 52, 5.37)) > 0.01/3600 {
+		t.Errorf("bearing = %.6f, want %.6f", p.Bearing, dms(306, 52, 5.37))
+	}
+	if math.Abs(p.Miles*KmPerMile-p.Km) > 1e-9 {
+		t.Errorf("inconsistent miles %.6f", p.Miles)
+	}
+
+	// one degree of longitude along the equator is exactly a/360 of the circumference
+	p, err = EllipsoidalPath(LatLong{0, 0}, LatLong{0, 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(p.Km-2*math.Pi*wgs84A/360) > 1e-9 || p.Bearing != 90 {
+		t.Errorf("equator got %v", p)
+	}
+
+	p, err = EllipsoidalPath(LatLong{10, 20}, LatLong{10, 20})
+	if err != nil || p.Km != 0 {
+		t.Errorf("coincident points got %v, %v", p, err)
+	}
+
+	if _, err := EllipsoidalPath(LatLong{0, 0}, LatLong{0.5, 179.7}); err == nil {
+		t.Errorf("expected error for nearly antipodal points")
+	}
+}
+
+func TestSphericalPath(t *testing.T) {
+	for _, test := range []struct {
+		from, to LatLong
+		km       float64
+		bearing  float64
+	}{
+		{LatLong{0, 0}, LatLong{0, 1}, 2 * math.Pi * earthRadius / 360, 90},
+		{LatLong{0, 0}, LatLong{0, -1}, 2 * math.Pi * earthRadius / 360, 270},
+		{LatLong{0, 0}, LatLong{90, 0}, math.Pi * earthRadius / 2, 0},
+		{LatLong{10, 30}, LatLong{-20, 30}, math.Pi * earthRadius / 6, 180},
+	} {
+		p := SphericalPath(test.from, test.to)
+		if math.Abs(p.Km-test.km) > 1e-6 || math.Abs(p.Bearing-test.bearing) > 1e-9 {
+			t.Errorf("SphericalPath(%v, %v) = %v, want %.6f km at %.1f", test.from, test.to, p, test.km, test.bearing)
+		}
+	}
+
+	// the two models should agree to within half a percent
+	from := LatLong{41.7148, -72.7272}
+	to := LatLong{-37.864701, 144.966135}
+	e, err := EllipsoidalPath(from, to)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := SphericalPath(from, to)
+	if math.Abs(e.Km-s.Km)/e.Km > 0.005 || math.Abs(e.Bearing-s.Bearing) > 1 {
+		t.Errorf("spherical %v and ellipsoidal %v are too different", s, e)
+	}
+}
+
+func TestLocatorPath(t *testing.T) {
+	p, err := LocatorPath("FN31pr", "FN31pr")
+	if err != nil || p.Km != 0 {
+		t.Errorf("same locator got %v, %v", p, err)
+	}
+
+	// FN42 to JN36 is a bit under 6000 km to the north-east
+	p, err = LocatorPath("FN42", "JN36")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Km < 5700 || p.Km > 6000 || p.Bearing < 40 || p.Bearing > 70 {
+		t.Errorf("FN42 to JN36 got %v", p)
+	}
+
+	// antipodal squares fall back to the spherical model
+	p, err = LocatorPath("JJ00", "AI09")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(p.Km-math.Pi*earthRadius) > 300 {
+		t.Errorf("antipodal got %v", p)
+	}
+
+	if _, err := LocatorPath("FN4", "JN36"); err == nil {
+		t.Errorf("expected error for bad locator")
+	}
+}
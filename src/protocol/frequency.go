@@ -0,0 +1,195 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+/*
+Frequency is an exact frequency as a whole number of nano-hertz. An int64
+covers ±9.2 GHz which is plenty for any band we transmit on, and a
+nano-hertz is far finer than anything we can measure. Unlike a float64, adding
+and subtracting frequencies is exact so tone offsets and errors don't pick up
+rounding noise.
+
+Frequencies add, subtract and multiply by integers with the normal operators.
+Use MulDiv to scale by a ratio without overflowing.
+*/
+type Frequency int64
+
+const (
+	NanoHertz  Frequency = 1
+	MicroHertz           = 1000 * NanoHertz
+	MilliHertz           = 1000 * MicroHertz
+	Hertz                = 1000 * MilliHertz
+	Kilohertz            = 1000 * Hertz
+	Megahertz            = 1000 * Kilohertz
+	Gigahertz            = 1000 * Megahertz
+
+	// MaxFrequency is the largest Frequency, a little over 9.2 GHz
+	MaxFrequency Frequency = math.MaxInt64
+)
+
+// units for parsing and formatting, largest first
+var frequencyUnits = []struct {
+	name  string
+	value Frequency
+	exp   int
+}{
+	{"GHz", Gigahertz, 18},
+	{"MHz", Megahertz, 15},
+	{"kHz", Kilohertz, 12},
+	{"Hz", Hertz, 9},
+	{"mHz", MilliHertz, 6},
+	{"µHz", MicroHertz, 3},
+	{"uHz", MicroHertz, 3},
+	{"nHz", NanoHertz, 0},
+}
+
+// FromHz converts a float64 in Hz, rounding to the nearest nano-hertz
+func FromHz(hz float64) Frequency {
+	whole := math.Trunc(hz)
+	return Frequency(whole)*Hertz + Frequency(math.Round((hz-whole)*1e9))
+}
+
+// Hz returns the frequency as a float64 in Hz
+func (f Frequency) Hz() float64 {
+	return float64(f/Hertz) + float64(f%Hertz)/1e9
+}
+
+// Rat returns the exact frequency in Hz
+func (f Frequency) Rat() *big.Rat {
+	return big.NewRat(int64(f), int64(Hertz))
+}
+
+/*
+MulDiv returns f * num / den rounded to the nearest nano-hertz. The product is
+computed with 128 bits so it can't overflow. A result too big for a Frequency,
+including anything non-zero divided by zero, saturates at MaxFrequency or
+-MaxFrequency.
+*/
+func (f Frequency) MulDiv(num, den uint64) Frequency {
+	if f == 0 || num == 0 {
+		return 0
+	}
+	neg := f < 0
+	x := uint64(f)
+	if neg {
+		x = -x
+	}
+	hi, lo := bits.Mul64(x, num)
+	lo, carry := bits.Add64(lo, den/2, 0)
+	hi += carry
+	// Div64 panics unless the quotient fits in 64 bits
+	q := uint64(math.MaxUint64)
+	if hi < den {
+		q, _ = bits.Div64(hi, lo, den)
+	}
+	if q > uint64(MaxFrequency) {
+		q = uint64(MaxFrequency)
+	}
+	if neg {
+		return -Frequency(q)
+	}
+	return Frequency(q)
+}
+
+/*
+ParseFrequency reads a decimal frequency with an optional unit such as
+"144.4905001464 MHz", "1.4648Hz" or "25000000". Without a unit the value is in
+Hz. An error is returned if the value has more precision than a nano-hertz or
+is too big.
+*/
+func ParseFrequency(s string) (Frequency, error) {
+	s = strings.TrimSpace(s)
+	exp := 9
+	for _, u := range frequencyUnits {
+		// plain Hz is a suffix of all the others so it comes last
+		if u.value != Hertz && strings.HasSuffix(s, u.name) {
+			s, exp = strings.TrimSuffix(s, u.name), u.exp
+			break
+		}
+	}
+	if exp == 9 {
+		s = strings.TrimSuffix(s, "Hz")
+	}
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(s, ".")
+	whole = strings.ReplaceAll(whole, "_", "")
+	if whole == "" && frac == "" {
+		return 0, errors.New("Frequency: no digits")
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > exp {
+		return 0, errors.New("Frequency: more precision than 1 nHz in " + strconv.Quote(s))
+	}
+	digits := whole + frac + strings.Repeat("0", exp-len(frac))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, errors.New("Frequency: invalid number " + strconv.Quote(s))
+		}
+	}
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, errors.New("Frequency: out of range " + strconv.Quote(s))
+	}
+	if neg {
+		v = -v
+	}
+	return Frequency(v), nil
+}
+
+/*
+String formats the frequency exactly in the largest unit that leaves a whole
+number part, such as "144.4905001464 MHz" or "-1.5 mHz".
+*/
+func (f Frequency) String() string {
+	if f == 0 {
+		return "0 Hz"
+	}
+	for _, u := range frequencyUnits {
+		if f >= u.value || -f >= u.value || u.value == NanoHertz {
+			return f.format(u.value, u.exp) + " " + u.name
+		}
+	}
+	return ""
+}
+
+// format writes f as a decimal number of units where unit = 10^exp
+func (f Frequency) format(unit Frequency, exp int) string {
+	sign := ""
+	x := uint64(f)
+	if f < 0 {
+		sign = "-"
+		x = uint64(-f)
+	}
+	whole := strconv.FormatUint(x/uint64(unit), 10)
+	if exp == 0 || x%uint64(unit) == 0 {
+		return sign + whole
+	}
+	frac := strconv.FormatUint(x%uint64(unit), 10)
+	frac = strings.Repeat("0", exp-len(frac)) + frac
+	return sign + whole + "." + strings.TrimRight(frac, "0")
+}
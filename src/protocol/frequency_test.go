@@ -0,0 +1,110 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestFrequency(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		f    Frequency
+		want string
+	}{
+		{"144.4905001464 MHz", 144_490_500_146_400_000, "144.4905001464 MHz"},
+		{"1.4648Hz", 1_464_800_000, "1.4648 Hz"},
+		{"25000000", 25 * Megahertz, "25 MHz"},
+		{"25_000_000 Hz", 25 * Megahertz, "25 MHz"},
+		{"1.5 GHz", 1500 * Megahertz, "1.5 GHz"},
+		{"14097.1 kHz", 14_097_100 * Hertz, "14.0971 MHz"},
+		{"-1.5 mHz", -1500 * MicroHertz, "-1.5 mHz"},
+		{"+2 µHz", 2 * MicroHertz, "2 µHz"},
+		{"2 uHz", 2 * MicroHertz, "2 µHz"},
+		{"7 nHz", 7, "7 nHz"},
+		{"0.000000001", 1, "1 nHz"},
+		{"0", 0, "0 Hz"},
+		{".5 Hz", 500 * MilliHertz, "500 mHz"},
+	} {
+		f, err := ParseFrequency(test.s)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.s, err)
+			continue
+		}
+		if f != test.f {
+			t.Errorf("%q: got %d nHz, want %d", test.s, f, test.f)
+		}
+		if f.String() != test.want {
+			t.Errorf("%q: formatted as %q, want %q", test.s, f.String(), test.want)
+		}
+		if g, err := ParseFrequency(f.String()); err != nil || g != f {
+			t.Errorf("%q: round trip gave %v, %v", test.s, g, err)
+		}
+	}
+
+	for _, s := range []string{"", "Hz", "1.0000000001 Hz", "1.5 nHz", "12a Hz", "1.2.3", "10 GHz", "--5"} {
+		if f, err := ParseFrequency(s); err == nil {
+			t.Errorf("%q: expected error, got %v", s, f)
+		}
+	}
+}
+
+func TestFrequencyArithmetic(t *testing.T) {
+	for _, test := range []struct {
+		f        Frequency
+		num, den uint64
+		want     Frequency
+	}{
+		{25 * Megahertz, 1, 3, 8_333_333_333_333_333},
+		{2 * NanoHertz, 1, 4, 1},
+		{-2 * NanoHertz, 1, 4, -1},
+		{-25 * Megahertz, 2, 3, -16_666_666_666_666_667},
+		// the product needs more than 64 bits
+		{900 * Megahertz, 1 << 40, 1 << 41, 450 * Megahertz},
+		{MaxFrequency, 3, 3, MaxFrequency},
+		{-MaxFrequency, 1, 1, -MaxFrequency},
+		{math.MinInt64, 1, 2, -(MaxFrequency/2 + 1)},
+		// results that don't fit saturate instead of wrapping or panicking
+		{5 * Gigahertz, 2, 1, MaxFrequency},
+		{-5 * Gigahertz, 2, 1, -MaxFrequency},
+		{Gigahertz, math.MaxUint64, 2, MaxFrequency},
+		{Hertz, 1, 0, MaxFrequency},
+		{-Hertz, 1, 0, -MaxFrequency},
+		{0, 1, 0, 0},
+		{Hertz, 0, 0, 0},
+	} {
+		if got := test.f.MulDiv(test.num, test.den); got != test.want {
+			t.Errorf("%v * %d / %d = %d, want %d", test.f, test.num, test.den, got, test.want)
+		}
+	}
+
+	for _, hz := range []float64{0, 1.5, -1.5, 14_097_100.25, 144_490_500.1464} {
+		f := FromHz(hz)
+		if d := f.Hz() - hz; d > 1e-6 || d < -1e-6 {
+			t.Errorf("%g Hz came back as %v", hz, f)
+		}
+	}
+	// exactly representable values convert exactly
+	if FromHz(14_097_100.25) != 14_097_100_250_000_000 {
+		t.Errorf("FromHz gave %d", FromHz(14_097_100.25))
+	}
+	if (144_490_500*Hertz + 1).Rat().Cmp(big.NewRat(144_490_500_000_000_001, 1_000_000_000)) != 0 {
+		t.Errorf("Rat is not exact")
+	}
+}
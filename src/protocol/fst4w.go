@@ -0,0 +1,279 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+This file contains code that implements the FST4W beacon modes from WSJT-X.
+FST4W sends the same 50 bits as a WSPR message, but protects them with a
+24-bit CRC and a (240,74) LDPC code instead of the convolutional code. The 240
+coded bits are Gray coded onto 120 4-FSK symbols and five 8-symbol sync words
+are added to give 160 symbols in all. These come out as tone numbers 0..3 just
+like WsprMessage, so the same transmit pipeline can send either one.
+
+The LDPC generator is derived from the parity checks of the code, which are a
+table of 240 random-looking rows in WSJT-X (lib/fst4/ldpc_240_74_parity.f90).
+That code is GPL licensed and the table hasn't been brought in yet, so for now
+FST4WMessage returns an error rather than symbols that can't be checked.
+*/
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// fst4wPayload is the number of message bits taken from PackBits
+	fst4wPayload = 50
+	// fst4wK is the number of message bits going into the LDPC code, payload plus CRC
+	fst4wK = fst4wPayload + 24
+	// fst4wN is the number of coded bits
+	fst4wN = 240
+	// crc24Poly is the 24-bit CRC polynomial used by WSJT-X with the x^24 term implied
+	crc24Poly = 0x00065b
+)
+
+var (
+	// FST4W modes with the samples per symbol that WSJT-X uses
+	FST4W120  = Mode{Name: "FST4W-120", SamplesPerSymbol: 8200, FrameSymbols: 160, Cycle: 2 * time.Minute, StartDelay: time.Second}
+	FST4W300  = Mode{Name: "FST4W-300", SamplesPerSymbol: 21504, FrameSymbols: 160, Cycle: 5 * time.Minute, StartDelay: time.Second}
+	FST4W900  = Mode{Name: "FST4W-900", SamplesPerSymbol: 66560, FrameSymbols: 160, Cycle: 15 * time.Minute, StartDelay: time.Second}
+	FST4W1800 = Mode{Name: "FST4W-1800", SamplesPerSymbol: 134400, FrameSymbols: 160, Cycle: 30 * time.Minute, StartDelay: time.Second}
+)
+
+var (
+	fst4wSync1 = []int8{0, 1, 3, 2, 1, 0, 2, 3}
+	fst4wSync2 = []int8{2, 3, 1, 0, 3, 2, 0, 1}
+	// grayTone maps a pair of coded bits to a tone
+	grayTone = [4]int8{0, 1, 3, 2}
+
+	// fst4wParity holds the parity checks of the (240,74) code from WSJT-X
+	// in the format accepted by NewLDPCGeneratorFromChecks
+	fst4wParity [][]int
+	errNoParity = errors.New("the FST4W parity checks from WSJT-X are not included")
+)
+
+/*
+LDPCGenerator is a systematic LDPC encoder. The code word is the K message
+bits followed by N-K parity bits, each of which is the parity of the message
+bits selected by one row of the generator.
+*/
+type LDPCGenerator struct {
+	N, K int
+	rows [][]int8
+}
+
+/*
+NewLDPCGenerator builds an encoder from a generator matrix in the hex format
+used by WSJT-X. There is one string per parity bit and each string holds K bits
+with the first message bit in the high bit of the first character. Unused bits
+in the last character must be zero.
+*/
+func NewLDPCGenerator(n, k int, hexRows []string) (*LDPCGenerator, error) {
+	if k <= 0 || n <= k {
+		return nil, fmt.Errorf("invalid LDPC code (%d,%d)", n, k)
+	}
+	if len(hexRows) != n-k {
+		return nil, fmt.Errorf("LDPC (%d,%d) needs %d generator rows, got %d", n, k, n-k, len(hexRows))
+	}
+	width := (k + 3) / 4
+	g := &LDPCGenerator{N: n, K: k, rows: make([][]int8, n-k)}
+	for i, hex := range hexRows {
+		if len(hex) != width {
+			return nil, fmt.Errorf("generator row %d should have %d hex digits, got %d", i, width, len(hex))
+		}
+		row := make([]int8, 4*width)
+		for j := 0; j < width; j++ {
+			v, ok := hexDigit(hex[j])
+			if !ok {
+				return nil, fmt.Errorf("invalid hex digit %q in generator row %d", hex[j], i)
+			}
+			for b := 0; b < 4; b++ {
+				row[4*j+b] = int8(v>>(3-b)) & 1
+			}
+		}
+		for _, extra := range row[k:] {
+			if extra != 0 {
+				return nil, fmt.Errorf("generator row %d has bits set beyond %d", i, k)
+			}
+		}
+		g.rows[i] = row[:k]
+	}
+	return g, nil
+}
+
+/*
+NewLDPCGeneratorFromChecks derives the systematic encoder for a code given by
+its parity checks. There is one slice per check holding the 1-based numbers of
+the code bits it covers, as in the Nm tables of WSJT-X. The checks are reduced
+by Gaussian elimination until each parity bit appears in exactly one of them,
+which fails if the last N-K columns of the parity-check matrix aren't
+independent.
+*/
+func NewLDPCGeneratorFromChecks(n, k int, checks [][]int) (*LDPCGenerator, error) {
+	if k <= 0 || n <= k {
+		return nil, fmt.Errorf("invalid LDPC code (%d,%d)", n, k)
+	}
+	if len(checks) != n-k {
+		return nil, fmt.Errorf("LDPC (%d,%d) needs %d parity checks, got %d", n, k, n-k, len(checks))
+	}
+	h := make([][]int8, n-k)
+	for i, check := range checks {
+		h[i] = make([]int8, n)
+		for _, bit := range check {
+			if bit < 1 || bit > n {
+				return nil, fmt.Errorf("parity check %d refers to bit %d", i, bit)
+			}
+			h[i][bit-1] ^= 1
+		}
+	}
+	// make the parity part of h the identity
+	for col := 0; col < n-k; col++ {
+		pivot := col
+		for pivot < n-k && h[pivot][k+col] == 0 {
+			pivot++
+		}
+		if pivot == n-k {
+			return nil, fmt.Errorf("parity bit %d isn't determined by the checks", k+col+1)
+		}
+		h[col], h[pivot] = h[pivot], h[col]
+		for i := range h {
+			if i != col && h[i][k+col] != 0 {
+				for j := range h[i] {
+					h[i][j] ^= h[col][j]
+				}
+			}
+		}
+	}
+	g := &LDPCGenerator{N: n, K: k, rows: make([][]int8, n-k)}
+	for i := range h {
+		g.rows[i] = h[i][:k:k]
+	}
+	return g, nil
+}
+
+func hexDigit(c byte) (int, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0'), true
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10, true
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10, true
+	}
+	return 0, false
+}
+
+// Encode returns the N bit code word for K message bits
+func (g *LDPCGenerator) Encode(message []int8) ([]int8, error) {
+	if len(message) != g.K {
+		return nil, fmt.Errorf("LDPC message should have %d bits, got %d", g.K, len(message))
+	}
+	out := make([]int8, g.N)
+	copy(out, message)
+	for i, row := range g.rows {
+		var p int8
+		for j, b := range row {
+			p ^= b & message[j]
+		}
+		out[g.K+i] = p
+	}
+	return out, nil
+}
+
+/*
+FST4WMessage encodes a call sign, locator and power as the 160 FST4W channel
+symbols. The arguments are checked in the same way as WsprMessage.
+*/
+func FST4WMessage(callsign, location string, power int) ([]int8, error) {
+	bits, err := PackBits(callsign, location, power)
+	if err != nil {
+		return nil, err
+	}
+	return FST4WSymbols(bits)
+}
+
+/*
+FST4WSymbols turns 50 packed message bits (bits 55...6 as returned by PackBits,
+PackType2 or PackType3) into the 160 FST4W channel symbols.
+*/
+func FST4WSymbols(bits uint64) ([]int8, error) {
+	if len(fst4wParity) == 0 {
+		return nil, errNoParity
+	}
+	g, err := NewLDPCGeneratorFromChecks(fst4wN, fst4wK, fst4wParity)
+	if err != nil {
+		return nil, err
+	}
+	return fst4wEncode(g, bits)
+}
+
+// fst4wEncode adds the CRC to the message bits and encodes them with g
+func fst4wEncode(g *LDPCGenerator, bits uint64) ([]int8, error) {
+	message := make([]int8, fst4wK)
+	for i := 0; i < fst4wPayload; i++ {
+		message[i] = int8(bits>>(55-i)) & 1
+	}
+	crc := CRC24(message[:fst4wPayload])
+	for i := 0; i < 24; i++ {
+		message[fst4wPayload+i] = int8(crc>>(23-i)) & 1
+	}
+	codeword, err := g.Encode(message)
+	if err != nil {
+		return nil, err
+	}
+	return fst4wFrame(codeword), nil
+}
+
+/*
+fst4wFrame Gray codes 240 bits onto 120 data symbols and interleaves them with
+the sync words in blocks of 30 to give the 160 symbol frame.
+*/
+func fst4wFrame(codeword []int8) []int8 {
+	data := make([]int8, len(codeword)/2)
+	for i := range data {
+		data[i] = grayTone[2*codeword[2*i]+codeword[2*i+1]]
+	}
+	out := make([]int8, 0, len(data)+40)
+	for block := 0; block < 4; block++ {
+		if block%2 == 0 {
+			out = append(out, fst4wSync1...)
+		} else {
+			out = append(out, fst4wSync2...)
+		}
+		out = append(out, data[30*block:30*block+30]...)
+	}
+	return append(out, fst4wSync1...)
+}
+
+/*
+CRC24 computes the 24-bit CRC that WSJT-X uses for FST4 and FST4W over a
+message stored one bit per byte. This is a plain polynomial remainder with no
+initial value or final inversion, so appending the CRC to the message gives a
+remainder of zero.
+*/
+func CRC24(message []int8) uint32 {
+	var r uint32
+	for _, b := range message {
+		top := (r>>23)&1 ^ uint32(b&1)
+		r = (r << 1) & 0xffffff
+		if top != 0 {
+			r ^= crc24Poly
+		}
+	}
+	return r
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCRC24(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 100; trial++ {
+		message := make([]int8, fst4wK)
+		for i := 0; i < fst4wPayload; i++ {
+			message[i] = int8(r.Intn(2))
+		}
+		crc := CRC24(message[:fst4wPayload])
+		if crc >= 1<<24 {
+			t.Fatalf("CRC %x has more than 24 bits", crc)
+		}
+		for i := 0; i < 24; i++ {
+			message[fst4wPayload+i] = int8(crc>>(23-i)) & 1
+		}
+		// the message with its CRC appended leaves no remainder
+		if CRC24(message) != 0 {
+			t.Errorf("CRC check failed for trial %d", trial)
+		}
+		// and every single bit error is detected
+		for i := range message {
+			message[i] ^= 1
+			if CRC24(message) == 0 {
+				t.Errorf("missed error in bit %d", i)
+			}
+			message[i] ^= 1
+		}
+	}
+	// a single one bit leaves the polynomial itself since x^24 = P + crc24Poly
+	if crc := CRC24([]int8{1}); crc != crc24Poly {
+		t.Errorf("CRC24(1) = %x", crc)
+	}
+}
+
+func TestLDPCGenerator(t *testing.T) {
+	// a Hamming (7,4) code in the WSJT-X format
+	g, err := NewLDPCGenerator(7, 4, []string{"D", "B", "7"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, test := range []struct {
+		message, want string
+	}{
+		{"0000", "0000000"},
+		{"1000", "1000110"},
+		{"0001", "0001111"},
+		{"1011", "1011010"},
+	} {
+		code, err := g.Encode(bitString(test.message))
+		if err != nil || string(showBits(code)) != test.want {
+			t.Errorf("Encode(%s) = %s, %v, want %s", test.message, showBits(code), err, test.want)
+		}
+	}
+	if _, err := g.Encode(make([]int8, 5)); err == nil {
+		t.Errorf("expected error for wrong message length")
+	}
+
+	for _, rows := range [][]string{{"D", "B"}, {"D", "B", "G"}, {"D", "B", "77"}} {
+		if _, err := NewLDPCGenerator(7, 4, rows); err == nil {
+			t.Errorf("expected error for %v", rows)
+		}
+	}
+	// 5 message bits use two hex digits with the last three bits unused
+	if _, err := NewLDPCGenerator(6, 5, []string{"F1"}); err == nil {
+		t.Errorf("expected error for bits beyond K")
+	}
+}
+
+func TestLDPCGeneratorFromChecks(t *testing.T) {
+	want, _ := NewLDPCGenerator(7, 4, []string{"D", "B", "7"})
+	// the Hamming (7,4) checks, mixed together so they need reducing
+	g, err := NewLDPCGeneratorFromChecks(7, 4, [][]int{{2, 3, 5, 6}, {1, 3, 4, 6}, {1, 2, 6, 7}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(g, want) {
+		t.Errorf("got %v, want %v", g.rows, want.rows)
+	}
+
+	// a full size code given as parity checks gives back the same generator
+	want = randomGenerator(rand.New(rand.NewSource(3)))
+	checks := make([][]int, len(want.rows))
+	for i, row := range want.rows {
+		for j, b := range row {
+			if b != 0 {
+				checks[i] = append(checks[i], j+1)
+			}
+		}
+		checks[i] = append(checks[i], fst4wK+i+1)
+	}
+	if g, err := NewLDPCGeneratorFromChecks(fst4wN, fst4wK, checks); err != nil || !reflect.DeepEqual(g, want) {
+		t.Errorf("generator from parity checks differs, %v", err)
+	}
+
+	for _, checks := range [][][]int{
+		{{1, 2, 4, 5}, {1, 3, 4, 6}},
+		{{1, 2, 4, 5}, {1, 3, 4, 6}, {2, 3, 4, 8}},
+		// the third check adds nothing so bit 7 is free
+		{{1, 2, 4, 5}, {1, 3, 4, 6}, {2, 3, 5, 6}},
+	} {
+		if _, err := NewLDPCGeneratorFromChecks(7, 4, checks); err == nil {
+			t.Errorf("expected error for %v", checks)
+		}
+	}
+}
+
+func TestFST4WFrame(t *testing.T) {
+	if _, err := FST4WMessage("K1ABC", "FN42", 37); err != errNoParity {
+		t.Errorf("expected missing parity error, got %v", err)
+	}
+	if _, err := FST4WMessage("K1ABC", "FN42", 5); err == nil {
+		t.Errorf("expected error for bad power")
+	}
+
+	// any generator will do to check the frame layout
+	g := randomGenerator(rand.New(rand.NewSource(2)))
+	bits, _ := PackBits("K1ABC", "FN42", 37)
+	symbols, err := fst4wEncode(g, bits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(symbols) != FST4W120.Symbols() {
+		t.Fatalf("got %d symbols", len(symbols))
+	}
+	for _, block := range []struct {
+		start int
+		sync  []int8
+	}{{0, fst4wSync1}, {38, fst4wSync2}, {76, fst4wSync1}, {114, fst4wSync2}, {152, fst4wSync1}} {
+		for i, s := range block.sync {
+			if symbols[block.start+i] != s {
+				t.Errorf("symbol %d = %d, want sync %d", block.start+i, symbols[block.start+i], s)
+			}
+		}
+	}
+
+	// the code is systematic so the first 25 data symbols carry the payload
+	for i := 0; i < 25; i++ {
+		pair := int8(bits>>(55-2*i))&1*2 + int8(bits>>(54-2*i))&1
+		if symbols[8+i] != grayTone[pair] {
+			t.Errorf("data symbol %d = %d, want %d", i, symbols[8+i], grayTone[pair])
+		}
+	}
+}
+
+// randomGenerator makes up a (240,74) generator in place of the WSJT-X one
+func randomGenerator(r *rand.Rand) *LDPCGenerator {
+	rows := make([]string, fst4wN-fst4wK)
+	for i := range rows {
+		var b strings.Builder
+		for j := 0; j < 19; j++ {
+			v := r.Intn(16)
+			if j == 18 {
+				v &= 0xc
+			}
+			b.WriteByte("0123456789ABCDEF"[v])
+		}
+		rows[i] = b.String()
+	}
+	g, err := NewLDPCGenerator(fst4wN, fst4wK, rows)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+func bitString(s string) []int8 {
+	out := make([]int8, len(s))
+	for i := range s {
+		out[i] = int8(s[i] - '0')
+	}
+	return out
+}
+
+func showBits(bits []int8) []byte {
+	out := make([]byte, len(bits))
+	for i, b := range bits {
+		out[i] = byte('0' + b)
+	}
+	return out
+}
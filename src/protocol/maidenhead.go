@@ -17,7 +17,10 @@
 package protocol
 
 import (
+	"fmt"
 	"math"
+	"math/big"
+	"strconv"
 )
 
 /*
@@ -25,36 +28,163 @@ This file contains support for foward and reverse encoding of lat-long pairs
 into the Maidenhead representation.
 */
 
-func Maidenhead(lat, long float64) string {
-	lat += 90
+/*
+Maidenhead converts a latitude and longitude to an 8 character Maidenhead
+locator. As it always has, the longitude is wrapped around so that any value
+gives a locator.
 
-	long += 180
-	for long < 0 {
+Earlier versions returned a meaningless locator for a latitude beyond ±90 or
+a NaN position. Those now give an empty string instead. Use MaidenheadN to get
+an error that says what is wrong with the position.
+*/
+func Maidenhead(lat, long float64) string {
+	long = math.Mod(long+180, 360)
+	if long < 0 {
 		long += 360
 	}
-	for long > 360 {
-		long -= 360
+	code, err := MaidenheadN(lat, long-180, 8)
+	if err != nil {
+		return ""
 	}
+	return code
+}
 
-	code := [8]byte{}
+/*
+MaidenheadN converts a latitude and longitude to a Maidenhead locator with
+the given number of characters. The precision can be 2, 4, 6, 8, 10 or 12
+characters where anything past the subsquare is made up of extended pairs that
+alternate between digits and letters.
 
-	code[0] = 'A' + byte(int(long/20))
-	code[1] = 'A' + byte(int(lat/10))
+The latitude must be in the range -90 to 90 and the longitude in the range
+-180 to 180. The north pole is put in the northernmost row of cells and a
+longitude of 180 is treated as -180 since these are the same meridian.
 
-	long = math.Mod(long, 20)
-	lat = math.Mod(lat, 10)
-	code[2] = '0' + byte(int(long/2))
-	code[3] = '0' + byte(int(lat))
+An error is returned for NaN, infinite or out of range positions or for an
+unsupported precision.
+*/
+func MaidenheadN(lat, long float64, chars int) (string, error) {
+	if chars < 2 || chars > 2*len(maidenheadPairs) || chars%2 != 0 {
+		return "", newFieldError("precision", strconv.Itoa(chars), -1,
+			fmt.Sprintf("must be an even number of characters from 2 to %d", 2*len(maidenheadPairs)))
+	}
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return "", newFieldError("latitude", fmt.Sprint(lat), -1, "out of range")
+	}
+	if math.IsNaN(long) || long < -180 || long > 180 {
+		return "", newFieldError("longitude", fmt.Sprint(long), -1, "out of range")
+	}
 
-	long = math.Mod(long, 2) * 24
-	lat = math.Mod(lat, 1) * 24
-	code[4] = 'A' + byte(int(long/2))
-	code[5] = 'A' + byte(int(lat))
+	// count the cells at this precision and find which one we are in
+	pairs := maidenheadPairs[:chars/2]
+	cells := int64(1)
+	for _, p := range pairs {
+		cells *= int64(p.n)
+	}
+	x := maidenheadCell(long, 180, 360, cells) % cells
+	y := maidenheadCell(lat, 90, 180, cells)
+	if y >= cells {
+		y = cells - 1
+	}
 
-	long = math.Mod(long, 2) * 10
-	lat = math.Mod(lat, 1) * 10
-	code[6] = '0' + byte(int(long/2))
-	code[7] = '0' + byte(int(lat))
+	// then peel off the digits from the finest to the coarsest
+	code := make([]byte, chars)
+	for i := len(pairs) - 1; i >= 0; i-- {
+		n := int64(pairs[i].n)
+		code[2*i] = pairs[i].base + byte(x%n)
+		code[2*i+1] = pairs[i].base + byte(y%n)
+		x /= n
+		y /= n
+	}
+	return string(code), nil
+}
 
-	return string(code[0:])
+/*
+maidenheadCell returns which of cells equal divisions of span degrees holds v
+once offset has been added. This is done exactly so that a point sitting on a
+grid line always lands in the cell to the north or east of the line.
+*/
+func maidenheadCell(v float64, offset, span, cells int64) int64 {
+	r := new(big.Rat).SetFloat64(v)
+	r.Add(r, big.NewRat(offset, 1))
+	r.Mul(r, big.NewRat(cells, span))
+	return new(big.Int).Quo(r.Num(), r.Denom()).Int64()
+}
+
+// LatLong is a position in degrees with north and east positive
+type LatLong struct {
+	Lat, Long float64
+}
+
+/*
+maidenheadPairs describes the successive pairs of characters in a locator. Each
+pair divides the previous cell into n parts in longitude and latitude using
+characters starting at base.
+*/
+var maidenheadPairs = []struct {
+	base byte
+	n    int
+}{
+	{'A', 18}, // field, 20° x 10°
+	{'0', 10}, // square, 2° x 1°
+	{'A', 24}, // subsquare, 5' x 2.5'
+	{'0', 10}, // extended square, 30" x 15"
+	{'A', 24}, // extended subsquare, 1.25" x 0.625"
+	{'0', 10}, // second extended square, 0.125" x 0.0625"
+}
+
+/*
+MaidenheadBox converts a Maidenhead locator of 2 to 12 characters into the
+centre of the area it covers and the south-west and north-east corners of that
+area. Letters may be upper or lower case.
+
+An error is returned if the locator has the wrong length or if any character is
+out of range for its position.
+*/
+func MaidenheadBox(locator string) (center, sw, ne LatLong, err error) {
+	n := len(locator)
+	if n < 2 || n > 2*len(maidenheadPairs) || n%2 != 0 {
+		return LatLong{}, LatLong{}, LatLong{}, newFieldError("locator", locator, -1,
+			fmt.Sprintf("must have 2 to %d characters in pairs", 2*len(maidenheadPairs)))
+	}
+	long, lat := -180.0, -90.0
+	width, height := 360.0, 180.0
+	for i := 0; i < n; i += 2 {
+		p := maidenheadPairs[i/2]
+		width /= float64(p.n)
+		height /= float64(p.n)
+		x, ok := maidenheadDigit(locator[i], p.base, p.n)
+		if !ok {
+			return LatLong{}, LatLong{}, LatLong{}, newFieldError("locator", locator, i, "invalid character")
+		}
+		y, ok := maidenheadDigit(locator[i+1], p.base, p.n)
+		if !ok {
+			return LatLong{}, LatLong{}, LatLong{}, newFieldError("locator", locator, i+1, "invalid character")
+		}
+		long += float64(x) * width
+		lat += float64(y) * height
+	}
+	sw = LatLong{lat, long}
+	ne = LatLong{lat + height, long + width}
+	center = LatLong{lat + height/2, long + width/2}
+	return center, sw, ne, nil
+}
+
+/*
+MaidenheadToLatLong returns the latitude and longitude of the centre of a
+Maidenhead locator. See MaidenheadBox for details.
+*/
+func MaidenheadToLatLong(locator string) (lat, long float64, err error) {
+	center, _, _, err := MaidenheadBox(locator)
+	return center.Lat, center.Long, err
+}
+
+// maidenheadDigit converts one character of a locator to its value
+func maidenheadDigit(c byte, base byte, n int) (int, bool) {
+	if base == 'A' && 'a' <= c && c <= 'z' {
+		c = c - 'a' + 'A'
+	}
+	if c < base || int(c-base) >= n {
+		return 0, false
+	}
+	return int(c - base), true
 }
@@ -16,7 +16,10 @@
 
 package protocol
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestMaidenhead(t *testing.T) {
 	tests := []struct {
@@ -40,3 +43,167 @@ func TestMaidenhead(t *testing.T) {
 		})
 	}
 }
+
+func TestMaidenheadBox(t *testing.T) {
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-9
+	}
+	tests := []struct {
+		loc    string
+		center LatLong
+		sw, ne LatLong
+	}{
+		{"JJ", LatLong{5, 10}, LatLong{0, 0}, LatLong{10, 20}},
+		{"AA", LatLong{-85, -170}, LatLong{-90, -180}, LatLong{-80, -160}},
+		{"RR", LatLong{85, 170}, LatLong{80, 160}, LatLong{90, 180}},
+		{"FN31", LatLong{41.5, -73}, LatLong{41, -74}, LatLong{42, -72}},
+		{"fn31pr", LatLong{41 + 17.5*2.5/60, -74 + 15.5*5/60}, LatLong{41 + 17*2.5/60, -74 + 15.0*5/60}, LatLong{41 + 18*2.5/60, -74 + 16.0*5/60}},
+		{"JJ00AA00", LatLong{0.125 / 60, 0.25 / 60}, LatLong{0, 0}, LatLong{0.25 / 60, 0.5 / 60}},
+		{"JJ00AA00AA", LatLong{0.125 / 60 / 24, 0.25 / 60 / 24}, LatLong{0, 0}, LatLong{0.25 / 60 / 24, 0.5 / 60 / 24}},
+		{"RR99XX99XX", LatLong{90 - 0.125/60/24, 180 - 0.25/60/24}, LatLong{90 - 0.25/60/24, 180 - 0.5/60/24}, LatLong{90, 180}},
+	}
+	for _, test := range tests {
+		t.Run(test.loc, func(t *testing.T) {
+			center, sw, ne, err := MaidenheadBox(test.loc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, c := range []struct{ got, want LatLong }{{center, test.center}, {sw, test.sw}, {ne, test.ne}} {
+				if !near(c.got.Lat, c.want.Lat) || !near(c.got.Long, c.want.Long) {
+					t.Errorf("got %v, want %v", c.got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMaidenheadBoxErrors(t *testing.T) {
+	for _, loc := range []string{"", "F", "FN3", "SN", "FA3X", "FN31PY", "FN31PRA5", "FN31PR55YA", "FN31PR55AA0A", "FN31PR55AA00AA", "F?"} {
+		if _, _, _, err := MaidenheadBox(loc); err == nil {
+			t.Errorf("MaidenheadBox(%q) expected error", loc)
+		}
+	}
+}
+
+func TestMaidenheadRoundTrip(t *testing.T) {
+	for lat := -89.9437; lat < 90; lat += 0.731 {
+		for long := -179.9371; long < 180; long += 1.377 {
+			mh := Maidenhead(lat, long)
+			for n := 2; n <= len(mh); n += 2 {
+				_, sw, ne, err := MaidenheadBox(mh[:n])
+				if err != nil {
+					t.Fatalf("MaidenheadBox(%q) unexpected error: %v", mh[:n], err)
+				}
+				if lat < sw.Lat || lat >= ne.Lat || long < sw.Long || long >= ne.Long {
+					t.Fatalf("%.4f, %.4f is not in the box for %s: %v %v", lat, long, mh[:n], sw, ne)
+				}
+			}
+		}
+	}
+}
+
+func TestMaidenheadN(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		chars     int
+		loc       string
+	}{
+		{41.7148, -72.7272, 2, "FN"},
+		{41.7148, -72.7272, 6, "FN31PR"},
+		{41.7148, -72.7272, 10, "FN31PR21RN"},
+		{0, 0, 12, "JJ00AA00AA00"},
+		{-90, -180, 12, "AA00AA00AA00"},
+		{90, 0, 4, "JR09"},
+		{90, 179.999999, 12, "RR99XX99XX99"},
+		{45, 180, 4, "AN05"},
+	}
+	for _, test := range tests {
+		got, err := MaidenheadN(test.lat, test.long, test.chars)
+		if err != nil {
+			t.Errorf("MaidenheadN(%v, %v, %d) unexpected error: %v", test.lat, test.long, test.chars, err)
+		}
+		if got != test.loc {
+			t.Errorf("MaidenheadN(%v, %v, %d) = %s, want %s", test.lat, test.long, test.chars, got, test.loc)
+		}
+	}
+
+	for _, bad := range []struct {
+		lat, long float64
+		chars     int
+	}{
+		{math.NaN(), 0, 6},
+		{0, math.NaN(), 6},
+		{90.001, 0, 6},
+		{-90.001, 0, 6},
+		{0, 180.5, 6},
+		{0, math.Inf(-1), 6},
+		{0, 0, 0},
+		{0, 0, 5},
+		{0, 0, 14},
+	} {
+		if _, err := MaidenheadN(bad.lat, bad.long, bad.chars); err == nil {
+			t.Errorf("MaidenheadN(%v, %v, %d) expected error", bad.lat, bad.long, bad.chars)
+		}
+	}
+	for _, bad := range []struct{ lat, long float64 }{{91, 0}, {-90.5, 0}, {math.NaN(), 0}, {0, math.Inf(1)}} {
+		if Maidenhead(bad.lat, bad.long) != "" {
+			t.Errorf("Maidenhead(%v, %v) should return empty string for invalid position", bad.lat, bad.long)
+		}
+	}
+	// longitudes wrap around like they always have
+	if mh := Maidenhead(41.7148, 360-72.7272); mh != Maidenhead(41.7148, -72.7272) {
+		t.Errorf("Maidenhead() got %s for a wrapped longitude", mh)
+	}
+}
+
+func TestMaidenheadNRoundTrip(t *testing.T) {
+	for lat := -89.9437; lat < 90; lat += 1.731 {
+		for long := -179.9371; long < 180; long += 2.377 {
+			mh, err := MaidenheadN(lat, long, 12)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for n := 2; n <= len(mh); n += 2 {
+				short, _ := MaidenheadN(lat, long, n)
+				if short != mh[:n] {
+					t.Fatalf("precision %d gives %s, not a prefix of %s", n, short, mh)
+				}
+				_, sw, ne, err := MaidenheadBox(short)
+				if err != nil {
+					t.Fatalf("MaidenheadBox(%q) unexpected error: %v", short, err)
+				}
+				if lat < sw.Lat || lat >= ne.Lat || long < sw.Long || long >= ne.Long {
+					t.Fatalf("%.4f, %.4f is not in the box for %s: %v %v", lat, long, short, sw, ne)
+				}
+			}
+		}
+	}
+}
+
+func TestMaidenheadGridLines(t *testing.T) {
+	for _, test := range []struct {
+		lat, long float64
+		want      string
+	}{
+		{8, 0, "JJ08AA00AA00"},
+		{0, 16, "JJ80AA00AA00"},
+		{10, 20, "KK00AA00AA00"},
+		{0.5, 1, "JJ00MM00AA00"},
+		{-0.5, -1, "II99MM00AA00"},
+		{-90, -180, "AA00AA00AA00"},
+		// the finest lines that a float64 can hit exactly
+		{1.0 / 256, 1.0 / 128, "JJ00AA00WW55"},
+		// just south of a line is still in the cell below
+		{math.Nextafter(8, 0), 0, "JJ07AX09AX09"},
+	} {
+		for n := 2; n <= len(test.want); n += 2 {
+			mh, err := MaidenheadN(test.lat, test.long, n)
+			if err != nil || mh != test.want[:n] {
+				t.Errorf("MaidenheadN(%v, %v, %d) = %q, %v, want %q", test.lat, test.long, n, mh, err, test.want[:n])
+			}
+		}
+	}
+	if mh := Maidenhead(8, 0); mh != "JJ08AA00" {
+		t.Errorf("Maidenhead(8, 0) = %q", mh)
+	}
+}
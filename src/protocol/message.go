@@ -0,0 +1,244 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+This file contains a typed representation of a WSPR message that covers all
+three message types along with the structured errors that are returned when
+a field can't be encoded or decoded.
+*/
+
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MessageType says which of the three WSPR message layouts is in use
+type MessageType int
+
+const (
+	// Type1 messages have a simple call sign, 4 character locator and power
+	Type1 MessageType = 1
+	// Type2 messages have a compound call sign and power but no locator
+	Type2 MessageType = 2
+	// Type3 messages have a call sign hash, 6 character locator and power
+	Type3 MessageType = 3
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case Type1, Type2, Type3:
+		return "Type " + strconv.Itoa(int(t))
+	default:
+		return "MessageType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+/*
+Message holds the decoded content of a WSPR message of any type. Locator is
+empty for Type 2 messages. For Type 3 messages, Hash holds the 15-bit call
+sign hash and CallSign is "<...>" after decoding because the call sign can't
+be recovered from the hash.
+*/
+type Message struct {
+	Type     MessageType
+	CallSign string
+	Locator  string
+	Power    int
+	Hash     uint32
+}
+
+/*
+FieldError describes a problem with one field of a message. Position is the
+index of the offending character in Value or -1 if the problem isn't with a
+particular character.
+*/
+type FieldError struct {
+	Field    string
+	Value    string
+	Position int
+	Reason   string
+}
+
+func (e *FieldError) Error() string {
+	if e.Position >= 0 {
+		return fmt.Sprintf("%s %q: %s at position %d", e.Field, e.Value, e.Reason, e.Position)
+	}
+	return fmt.Sprintf("%s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+func newFieldError(field, value string, position int, reason string) *FieldError {
+	return &FieldError{Field: field, Value: value, Position: position, Reason: reason}
+}
+
+/*
+NewMessage builds a message and picks the type from the content. A call sign
+with a '/' gives a Type 2 message (the locator must be empty), a 6 character
+locator gives a Type 3 message and anything else gives a Type 1 message. An
+error is returned if the message can't be encoded.
+*/
+func NewMessage(callsign, locator string, power int) (Message, error) {
+	m := Message{Type: Type1, CallSign: callsign, Locator: locator, Power: power}
+	switch {
+	case strings.Contains(callsign, "/"):
+		if locator != "" {
+			return Message{}, newFieldError("locator", locator, -1, "must be empty with a compound call sign")
+		}
+		m.Type = Type2
+	case len(locator) == 6:
+		m.Type = Type3
+		m.Hash = Hash(callsign)
+	}
+	if _, err := m.Pack(); err != nil {
+		return Message{}, err
+	}
+	return m, nil
+}
+
+/*
+ParseMessage reads a message in the form produced by String such as
+"K1ABC FN42 37", "PJ4/K1ABC 37", "<K1ABC> FN42AX 37" or "<#6521> FN42AX 37".
+The last form keeps the hash of a call sign that isn't known. A bare "<...>"
+is an error since the hash it stood for is gone.
+*/
+func ParseMessage(s string) (Message, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 || len(fields) > 3 {
+		return Message{}, newFieldError("message", s, -1, "must have 2 or 3 fields")
+	}
+	power, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return Message{}, newFieldError("power", fields[len(fields)-1], -1, "not a number")
+	}
+	locator := ""
+	if len(fields) == 3 {
+		locator = fields[1]
+	}
+	if fields[0] == "<...>" {
+		return Message{}, newFieldError("call sign", fields[0], -1, "hash is unknown")
+	}
+	if strings.HasPrefix(fields[0], "<#") && strings.HasSuffix(fields[0], ">") {
+		hash, err := strconv.ParseUint(fields[0][2:len(fields[0])-1], 10, 15)
+		if err != nil {
+			return Message{}, newFieldError("call sign", fields[0], -1, "invalid hash")
+		}
+		m := Message{Type: Type3, CallSign: "<...>", Locator: locator, Power: power, Hash: uint32(hash)}
+		if _, err := m.Pack(); err != nil {
+			return Message{}, err
+		}
+		return m, nil
+	}
+	callsign := strings.TrimSuffix(strings.TrimPrefix(fields[0], "<"), ">")
+	return NewMessage(callsign, locator, power)
+}
+
+/*
+Pack encodes the message into bits 55...6 using PackBits, PackType2 or
+PackType3 according to the message type. A Type 3 message with no call sign
+(or "<...>") is packed using Hash instead.
+*/
+func (m Message) Pack() (uint64, error) {
+	switch m.Type {
+	case Type1:
+		return PackBits(m.CallSign, m.Locator, m.Power)
+	case Type2:
+		return PackType2(m.CallSign, m.Power)
+	case Type3:
+		if m.CallSign == "" || m.CallSign == "<...>" {
+			return packType3Hash(m.Hash, m.Locator, m.Power)
+		}
+		return PackType3(m.CallSign, m.Locator, m.Power)
+	default:
+		return 0, newFieldError("type", strconv.Itoa(int(m.Type)), -1, "unknown message type")
+	}
+}
+
+// Encode returns the 162 channel symbols for the message
+func (m Message) Encode() ([]int8, error) {
+	bits, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	return channelSymbols(bits), nil
+}
+
+/*
+Decode fills in the message from 162 received channel symbols. The message
+is left unchanged if there is an error.
+*/
+func (m *Message) Decode(symbols []int8) error {
+	bits, err := DecodeBits(symbols)
+	if err != nil {
+		return err
+	}
+	r, err := UnpackMessage(bits)
+	if err != nil {
+		return err
+	}
+	*m = r
+	return nil
+}
+
+/*
+String formats the message the way WSJT-X shows it, except that a Type 3
+message with an unknown call sign shows the hash as "<#6521>" rather than
+"<...>" so that ParseMessage can read it back.
+*/
+func (m Message) String() string {
+	switch m.Type {
+	case Type2:
+		return fmt.Sprintf("%s %d", m.CallSign, m.Power)
+	case Type3:
+		call := m.CallSign
+		if call == "" || call == "<...>" {
+			call = fmt.Sprintf("<#%d>", m.Hash)
+		} else if !strings.HasPrefix(call, "<") {
+			call = "<" + call + ">"
+		}
+		return fmt.Sprintf("%s %s %d", call, m.Locator, m.Power)
+	default:
+		return fmt.Sprintf("%s %s %d", m.CallSign, m.Locator, m.Power)
+	}
+}
+
+/*
+UnpackMessage decodes bits 55...6 into a message of any type. The type is
+recognized from the power field. Negative powers mark Type 3 messages and
+powers that don't end in 0, 3 or 7 mark Type 2 messages.
+*/
+func UnpackMessage(bits uint64) (Message, error) {
+	ntype := UnpackPower((bits >> 6) & 0x7f)
+	if ntype < 0 {
+		return unpackType3(bits)
+	}
+	callsign, err := UnpackCallSign(bits >> 28)
+	if err != nil {
+		return Message{}, err
+	}
+	if !ValidPower(ntype) {
+		if ntype <= 62 {
+			return unpackType2(callsign, bits)
+		}
+		return Message{}, newFieldError("power", strconv.Itoa(ntype), -1, "encoded value out of range")
+	}
+	locator, err := UnpackLocator((bits >> 13) & 0x7fff)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Type: Type1, CallSign: callsign, Locator: locator, Power: ntype}, nil
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		text    string
+		kind    MessageType
+		decoded string
+	}{
+		{"K1ABC FN42 37", Type1, "K1ABC FN42 37"},
+		{"PJ4/K1ABC 37", Type2, "PJ4/K1ABC 37"},
+		{"K1ABC/7 10", Type2, "K1ABC/7 10"},
+		{"<K1ABC> FN42AX 37", Type3, fmt.Sprintf("<#%d> FN42AX 37", Hash("K1ABC"))},
+	} {
+		m, err := ParseMessage(test.text)
+		if err != nil {
+			t.Fatalf("ParseMessage(%q) unexpected error: %v", test.text, err)
+		}
+		if m.Type != test.kind || m.String() != test.text {
+			t.Errorf("ParseMessage(%q) = %v (%v)", test.text, m, m.Type)
+		}
+		symbols, err := m.Encode()
+		if err != nil {
+			t.Fatalf("%v.Encode() unexpected error: %v", m, err)
+		}
+		var r Message
+		if err := r.Decode(symbols); err != nil {
+			t.Fatalf("Decode(%q) unexpected error: %v", test.text, err)
+		}
+		if r.Type != test.kind || r.String() != test.decoded || r.Hash != m.Hash {
+			t.Errorf("Decode(%q) = %v (%v, hash %d)", test.text, r, r.Type, r.Hash)
+		}
+
+		// and its text parses back to the same bits
+		bits, _ := m.Pack()
+		p, err := ParseMessage(r.String())
+		if err != nil {
+			t.Fatalf("ParseMessage(%q) unexpected error: %v", r.String(), err)
+		}
+		if got, err := p.Pack(); err != nil || got != bits || p.Hash != m.Hash {
+			t.Errorf("ParseMessage(%q) packs to %x, %v, want %x", r.String(), got, err, bits)
+		}
+
+		// a decoded message encodes to the same symbols
+		again, err := r.Encode()
+		if err != nil {
+			t.Fatalf("%v.Encode() unexpected error: %v", r, err)
+		}
+		for i := range again {
+			if again[i] != symbols[i] {
+				t.Errorf("re-encoding %q differs at symbol %d", test.text, i)
+				break
+			}
+		}
+	}
+}
+
+func TestMessageErrors(t *testing.T) {
+	for _, test := range []struct {
+		callsign, locator string
+		power             int
+		field             string
+		position          int
+	}{
+		{"K1A3C", "FN42", 37, "callsign", 3},
+		{"K1ABCDE", "FN42", 37, "callsign", -1},
+		{"PJ4/K1A3C", "", 37, "callsign", 7},
+		{"PJ4X/K1ABC", "", 37, "prefix", -1},
+		{"K1ABC/0X", "", 37, "suffix", 0},
+		{"K1ABC", "FN4X", 37, "locator", 3},
+		{"K1ABC", "FN42AZ", 37, "locator", 5},
+		{"PJ4/K1ABC", "FN42", 37, "locator", -1},
+		{"K1ABC", "FN42", 5, "power", -1},
+	} {
+		_, err := NewMessage(test.callsign, test.locator, test.power)
+		var fe *FieldError
+		if !errors.As(err, &fe) {
+			t.Errorf("NewMessage(%q, %q, %d) got %v, want FieldError", test.callsign, test.locator, test.power, err)
+			continue
+		}
+		if fe.Field != test.field || fe.Position != test.position {
+			t.Errorf("NewMessage(%q, %q, %d) got %q at %d, want %q at %d",
+				test.callsign, test.locator, test.power, fe.Field, fe.Position, test.field, test.position)
+		}
+	}
+
+	for _, bad := range []string{"", "K1ABC", "K1ABC FN42 xx", "K1ABC FN42 37 extra", "<...> FN42AX 37", "<#40000> FN42AX 37", "<#12x> FN42AX 37", "<#6521> FN42 37"} {
+		if _, err := ParseMessage(bad); err == nil {
+			t.Errorf("ParseMessage(%q) expected error", bad)
+		}
+	}
+	if _, err := (Message{Type: 4}).Pack(); err == nil {
+		t.Errorf("expected error for unknown message type")
+	}
+}
+
+func TestUnpackMessage(t *testing.T) {
+	// the power field alone picks the message type
+	for _, test := range []struct {
+		power int
+		kind  MessageType
+	}{
+		{37, Type1}, {38, Type2}, {39, Type2}, {-38, Type3},
+	} {
+		// N42AXF is a valid call sign and also FN42AX rotated
+		c, _ := CallSign("N42AXF")
+		l, _ := Locator("FN42")
+		m, err := UnpackMessage(c<<28 + l<<13 + Power(test.power)<<6)
+		if err != nil || m.Type != test.kind {
+			t.Errorf("power %d got %v, %v", test.power, m.Type, err)
+		}
+	}
+	if _, err := UnpackMessage(Power(63) << 6); err == nil {
+		t.Errorf("expected error for out of range power")
+	}
+}
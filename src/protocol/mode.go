@@ -0,0 +1,131 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+This file describes the timing of the WSPR transmission modes. The WSPR modes
+all send the same 162 channel symbols, but the slow modes used on LF and MF
+stretch out each symbol and squeeze the tones closer together so that the
+signal can be received further below the noise. The FST4W modes are defined
+with the FST4W encoder.
+*/
+
+package protocol
+
+import (
+	"strings"
+	"time"
+)
+
+// SampleRate is the audio sample rate in Hz that WSJT-X uses to define the modes
+const SampleRate = 12000
+
+/*
+Mode describes the timing of a WSPR transmission. The symbol period is exactly
+SamplesPerSymbol / SampleRate seconds and the tones are spaced by the inverse of
+that. A transmission has FrameSymbols symbols and starts StartDelay after the
+beginning of a cycle. Cycles start at multiples of Cycle past the hour.
+*/
+type Mode struct {
+	Name             string
+	SamplesPerSymbol int
+	FrameSymbols     int
+	Cycle            time.Duration
+	StartDelay       time.Duration
+}
+
+var (
+	// WSPR2 is the standard 2-minute mode used on HF
+	WSPR2 = Mode{Name: "WSPR-2", SamplesPerSymbol: 8192, FrameSymbols: 162, Cycle: 2 * time.Minute, StartDelay: time.Second}
+	// WSPR15 is the slow 15-minute mode used on 2200m and 630m
+	WSPR15 = Mode{Name: "WSPR-15", SamplesPerSymbol: 65536, FrameSymbols: 162, Cycle: 15 * time.Minute, StartDelay: time.Second}
+)
+
+// Modes lists all the known modes
+var Modes = []Mode{WSPR2, WSPR15, FST4W120, FST4W300, FST4W900, FST4W1800}
+
+/*
+ModeByName finds a mode by name ignoring case. Both "WSPR-2" and "WSPR2" are
+accepted.
+*/
+func ModeByName(name string) (Mode, error) {
+	key := strings.ReplaceAll(strings.ToUpper(name), "-", "")
+	for _, m := range Modes {
+		if strings.ReplaceAll(m.Name, "-", "") == key {
+			return m, nil
+		}
+	}
+	return Mode{}, newFieldError("mode", name, -1, "unknown mode")
+}
+
+func (m Mode) String() string {
+	return m.Name
+}
+
+// Symbols returns the number of channel symbols in a transmission
+func (m Mode) Symbols() int {
+	return m.FrameSymbols
+}
+
+// SymbolPeriod returns the length of one symbol in seconds
+func (m Mode) SymbolPeriod() float64 {
+	return float64(m.SamplesPerSymbol) / SampleRate
+}
+
+// SymbolDuration returns the length of one symbol rounded to the nearest nanosecond
+func (m Mode) SymbolDuration() time.Duration {
+	return m.SymbolOffset(1)
+}
+
+/*
+SymbolOffset returns the time from the start of the transmission to the start
+of symbol i. This is computed directly rather than by adding up symbol
+durations so rounding errors don't accumulate.
+*/
+func (m Mode) SymbolOffset(i int) time.Duration {
+	ns := int64(i) * int64(m.SamplesPerSymbol) * int64(time.Second)
+	return time.Duration((ns + SampleRate/2) / SampleRate)
+}
+
+// ToneSpacing returns the spacing between adjacent tones in Hz
+func (m Mode) ToneSpacing() float64 {
+	return SampleRate / float64(m.SamplesPerSymbol)
+}
+
+// Bandwidth returns the total width of the 4 tones in Hz
+func (m Mode) Bandwidth() float64 {
+	return 4 * m.ToneSpacing()
+}
+
+// Duration returns the length of a complete transmission
+func (m Mode) Duration() time.Duration {
+	return m.SymbolOffset(m.Symbols())
+}
+
+/*
+NextStart returns the first time at or after t that a transmission can begin.
+Cycles are aligned to the hour in UTC so WSPR-2 transmissions start one
+second after each even minute and WSPR-15 transmissions one second after 0,
+15, 30 and 45 minutes past the hour.
+*/
+func (m Mode) NextStart(t time.Time) time.Time {
+	hour := t.UTC().Truncate(time.Hour)
+	late := t.Sub(hour) - m.StartDelay
+	cycles := time.Duration(0)
+	if late > 0 {
+		cycles = (late + m.Cycle - 1) / m.Cycle
+	}
+	return hour.Add(m.StartDelay + cycles*m.Cycle)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestModeTiming(t *testing.T) {
+	if math.Abs(WSPR2.ToneSpacing()-1.46484375) > 1e-12 || math.Abs(WSPR2.SymbolPeriod()-0.682666666667) > 1e-9 {
+		t.Errorf("WSPR-2 got %v Hz, %v s", WSPR2.ToneSpacing(), WSPR2.SymbolPeriod())
+	}
+	if WSPR15.ToneSpacing()*8 != WSPR2.ToneSpacing() || WSPR15.SymbolDuration() != WSPR2.SymbolOffset(8) {
+		t.Errorf("WSPR-15 should be 8 times slower than WSPR-2")
+	}
+	if WSPR2.SymbolDuration() != 682666667 {
+		t.Errorf("WSPR-2 symbol is %v", WSPR2.SymbolDuration())
+	}
+	// 162 symbols of 8192 samples is exactly 110.592 seconds
+	if WSPR2.Duration() != 110592*time.Millisecond || WSPR15.Duration() != 8*110592*time.Millisecond {
+		t.Errorf("durations %v and %v", WSPR2.Duration(), WSPR15.Duration())
+	}
+	for _, m := range Modes {
+		if m.StartDelay+m.Duration() > m.Cycle {
+			t.Errorf("%v doesn't fit in its cycle", m)
+		}
+	}
+}
+
+func TestModeByName(t *testing.T) {
+	for _, name := range []string{"WSPR-15", "wspr15", "Wspr-15"} {
+		if m, err := ModeByName(name); err != nil || m != WSPR15 {
+			t.Errorf("ModeByName(%q) = %v, %v", name, m, err)
+		}
+	}
+	if _, err := ModeByName("FT8"); err == nil {
+		t.Errorf("expected error for unknown mode")
+	}
+}
+
+func TestNextStart(t *testing.T) {
+	at := func(h, m, s, ms int) time.Time {
+		return time.Date(2025, 3, 1, h, m, s, ms*1e6, time.UTC)
+	}
+	for _, test := range []struct {
+		mode     Mode
+		now, due time.Time
+	}{
+		{WSPR2, at(10, 0, 0, 0), at(10, 0, 1, 0)},
+		{WSPR2, at(10, 0, 1, 0), at(10, 0, 1, 0)},
+		{WSPR2, at(10, 0, 1, 1), at(10, 2, 1, 0)},
+		{WSPR2, at(10, 59, 30, 0), at(11, 0, 1, 0)},
+		{WSPR15, at(10, 3, 0, 0), at(10, 15, 1, 0)},
+		{WSPR15, at(10, 45, 1, 0), at(10, 45, 1, 0)},
+		{WSPR15, at(10, 50, 0, 0), at(11, 0, 1, 0)},
+	} {
+		if got := test.mode.NextStart(test.now); !got.Equal(test.due) {
+			t.Errorf("%v.NextStart(%v) = %v, want %v", test.mode, test.now, got, test.due)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+This file contains code for handling the power levels that WSPR allows. Only
+powers from 0 to 60 dBm that end in 0, 3 or 7 can be sent. These correspond
+to the usual 1-2-5 sequence of powers in watts. Other values are used to mark
+Type 2 messages so sending them in a Type 1 message would be misread by a
+receiver.
+*/
+
+package protocol
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+/*
+PowerLevels lists the legal WSPR powers in dBm along with the nominal power in
+watts that each one stands for.
+*/
+var PowerLevels = []struct {
+	DBm   int
+	Watts float64
+}{
+	{0, 0.001},
+	{3, 0.002},
+	{7, 0.005},
+	{10, 0.01},
+	{13, 0.02},
+	{17, 0.05},
+	{20, 0.1},
+	{23, 0.2},
+	{27, 0.5},
+	{30, 1},
+	{33, 2},
+	{37, 5},
+	{40, 10},
+	{43, 20},
+	{47, 50},
+	{50, 100},
+	{53, 200},
+	{57, 500},
+	{60, 1000},
+}
+
+// ValidPower returns true if dBm is one of the legal WSPR power levels
+func ValidPower(dBm int) bool {
+	d := dBm % 10
+	return dBm >= 0 && dBm <= 60 && (d == 0 || d == 3 || d == 7)
+}
+
+func errIllegalPower(dBm int) error {
+	return newFieldError("power", strconv.Itoa(dBm), -1, "must be 0 to 60 dBm ending in 0, 3 or 7")
+}
+
+/*
+NearestPower rounds a power in dBm to the nearest legal WSPR power level.
+Powers outside the legal range are clamped to 0 or 60 dBm. A power exactly
+half way between two levels is rounded down so that we never claim more
+power than we have.
+*/
+func NearestPower(dBm float64) int {
+	best := PowerLevels[0].DBm
+	for _, p := range PowerLevels[1:] {
+		if math.Abs(float64(p.DBm)-dBm) < math.Abs(float64(best)-dBm) {
+			best = p.DBm
+		}
+	}
+	return best
+}
+
+/*
+PowerFromWatts converts a power in watts to the nearest legal WSPR power level.
+An error is returned if the power is not positive.
+*/
+func PowerFromWatts(watts float64) (int, error) {
+	if math.IsNaN(watts) || watts <= 0 || math.IsInf(watts, 1) {
+		return 0, newFieldError("power", fmt.Sprintf("%v W", watts), -1, "must be positive")
+	}
+	return NearestPower(10*math.Log10(watts) + 30), nil
+}
+
+/*
+PowerFromMilliwatts converts a power in milliwatts to the nearest legal WSPR
+power level. An error is returned if the power is not positive.
+*/
+func PowerFromMilliwatts(mW float64) (int, error) {
+	return PowerFromWatts(mW / 1000)
+}
+
+/*
+Watts returns the nominal power in watts for a legal WSPR power level. These
+are the round numbers that the power levels stand for rather than the exact
+conversion so 37 dBm gives 5 W rather than 5.0119 W.
+*/
+func Watts(dBm int) (float64, error) {
+	for _, p := range PowerLevels {
+		if p.DBm == dBm {
+			return p.Watts, nil
+		}
+	}
+	return 0, errIllegalPower(dBm)
+}
+
+/*
+Milliwatts returns the nominal power in milliwatts for a legal WSPR power
+level. See Watts for details.
+*/
+func Milliwatts(dBm int) (float64, error) {
+	w, err := Watts(dBm)
+	return w * 1000, err
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidPower(t *testing.T) {
+	legal := map[int]bool{}
+	for _, p := range PowerLevels {
+		legal[p.DBm] = true
+	}
+	for dBm := -20; dBm <= 80; dBm++ {
+		if ValidPower(dBm) != legal[dBm] {
+			t.Errorf("ValidPower(%d) = %v", dBm, ValidPower(dBm))
+		}
+	}
+	if len(legal) != 19 {
+		t.Errorf("expected 19 power levels, got %d", len(legal))
+	}
+}
+
+func TestNearestPower(t *testing.T) {
+	for _, test := range []struct {
+		dBm  float64
+		want int
+	}{
+		{-3, 0}, {0, 0}, {1.4, 0}, {1.5, 0}, {1.6, 3}, {5, 3}, {5.1, 7},
+		{36.99, 37}, {38.6, 40}, {60, 60}, {75, 60},
+	} {
+		if got := NearestPower(test.dBm); got != test.want {
+			t.Errorf("NearestPower(%v) = %d, want %d", test.dBm, got, test.want)
+		}
+	}
+}
+
+func TestPowerFromWatts(t *testing.T) {
+	for _, p := range PowerLevels {
+		got, err := PowerFromWatts(p.Watts)
+		if err != nil || got != p.DBm {
+			t.Errorf("PowerFromWatts(%v) = %d, %v, want %d", p.Watts, got, err, p.DBm)
+		}
+		got, err = PowerFromMilliwatts(p.Watts * 1000)
+		if err != nil || got != p.DBm {
+			t.Errorf("PowerFromMilliwatts(%v) = %d, %v, want %d", p.Watts*1000, got, err, p.DBm)
+		}
+		w, err := Watts(p.DBm)
+		if err != nil || w != p.Watts {
+			t.Errorf("Watts(%d) = %v, %v", p.DBm, w, err)
+		}
+		// nominal values are within 5% of the exact conversion
+		if exact := math.Pow(10, float64(p.DBm-30)/10); math.Abs(w-exact)/exact > 0.05 {
+			t.Errorf("Watts(%d) = %v is too far from %v", p.DBm, w, exact)
+		}
+	}
+	if got, _ := PowerFromWatts(3.5); got != 37 {
+		t.Errorf("PowerFromWatts(3.5) = %d, want 37", got)
+	}
+	if got, _ := PowerFromMilliwatts(250); got != 23 {
+		t.Errorf("PowerFromMilliwatts(250) = %d, want 23", got)
+	}
+	for _, bad := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		if _, err := PowerFromWatts(bad); err == nil {
+			t.Errorf("PowerFromWatts(%v) expected error", bad)
+		}
+	}
+	if _, err := Watts(5); err == nil {
+		t.Errorf("Watts(5) expected error")
+	}
+	if mw, err := Milliwatts(37); err != nil || mw != 5000 {
+		t.Errorf("Milliwatts(37) = %v, %v", mw, err)
+	}
+}
+
+func TestIllegalPowerRejected(t *testing.T) {
+	for _, dBm := range []int{-3, 5, 36, 61} {
+		if _, err := WsprMessage("K1ABC", "FN42", dBm); err == nil {
+			t.Errorf("WsprMessage() expected error for %d dBm", dBm)
+		}
+		if _, err := PackBits("K1ABC", "FN42", dBm); err == nil {
+			t.Errorf("PackBits() expected error for %d dBm", dBm)
+		}
+	}
+}
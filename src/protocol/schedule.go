@@ -0,0 +1,144 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+This file turns channel symbols into a timeline of RF frequencies so that the
+code driving hardware (or a simulator) doesn't need to know anything about
+WSPR timing.
+*/
+
+package protocol
+
+import (
+	"strconv"
+	"time"
+)
+
+/*
+Step is one symbol of a transmission. The transmitter should switch to
+Frequency at Start.
+*/
+type Step struct {
+	Start     time.Time
+	Tone      int8
+	Frequency Frequency
+}
+
+/*
+Schedule is the timeline for one transmission. The transmitter should be
+turned off at End.
+*/
+type Schedule struct {
+	Mode  Mode
+	Steps []Step
+	End   time.Time
+}
+
+/*
+NewSchedule computes the timeline for sending symbols starting at start. The
+RF frequency is the dial frequency plus the audio offset. As in WSJT-X, the
+audio offset is the centre of the signal so the four tones sit at
+offset + (tone - 1.5) * spacing.
+
+Each frequency and start time is computed directly from the symbol index
+rather than by adding up steps so there is no accumulated rounding. The tone
+offsets are exact ratios of the sample rate and are rounded once to the
+nearest nano-hertz.
+
+An error is returned if the number of symbols doesn't match the mode or a
+symbol isn't one of the 4 tones.
+*/
+func NewSchedule(symbols []int8, dial, offset Frequency, mode Mode, start time.Time) (Schedule, error) {
+	if len(symbols) != mode.Symbols() {
+		return Schedule{}, newFieldError("symbols", strconv.Itoa(len(symbols)), -1,
+			"wrong number of symbols for "+mode.Name)
+	}
+	if dial <= 0 {
+		return Schedule{}, newFieldError("dial", dial.String(), -1, "must be positive")
+	}
+	// tone k is (2k - 3) / 2 tone spacings from the centre
+	var tones [4]Frequency
+	for k := range tones {
+		tones[k] = dial + offset + Frequency(2*k-3).MulDiv(SampleRate*uint64(Hertz), 2*uint64(mode.SamplesPerSymbol))
+	}
+	s := Schedule{
+		Mode:  mode,
+		Steps: make([]Step, len(symbols)),
+		End:   start.Add(mode.Duration()),
+	}
+	for i, tone := range symbols {
+		if tone < 0 || tone > 3 {
+			return Schedule{}, newFieldError("symbols", strconv.Itoa(int(tone)), i, "tone must be 0 to 3")
+		}
+		s.Steps[i] = Step{
+			Start:     start.Add(mode.SymbolOffset(i)),
+			Tone:      tone,
+			Frequency: tones[tone],
+		}
+	}
+	return s, nil
+}
+
+/*
+At returns the step being sent at time t. The second return value is false if t
+is before the start or at or after the end of the transmission.
+*/
+func (s Schedule) At(t time.Time) (Step, bool) {
+	if len(s.Steps) == 0 || t.Before(s.Steps[0].Start) || !t.Before(s.End) {
+		return Step{}, false
+	}
+	// start times are rounded to the nearest nanosecond so the index
+	// estimate can be off by one right at a boundary
+	i := int(int64(t.Sub(s.Steps[0].Start)) * SampleRate / (int64(s.Mode.SamplesPerSymbol) * int64(time.Second)))
+	if i >= len(s.Steps) {
+		i = len(s.Steps) - 1
+	}
+	for i > 0 && t.Before(s.Steps[i].Start) {
+		i--
+	}
+	for i+1 < len(s.Steps) && !t.Before(s.Steps[i+1].Start) {
+		i++
+	}
+	return s.Steps[i], true
+}
+
+/*
+Points returns the schedule as one Point per symbol with abrupt changes
+between tones. Use Shaped for smooth transitions.
+*/
+func (s Schedule) Points() []Point {
+	points := make([]Point, len(s.Steps))
+	for i, step := range s.Steps {
+		points[i] = Point{At: step.Start, Frequency: step.Frequency}
+	}
+	return points
+}
+
+/*
+Divide returns a copy of the schedule with every frequency divided by n and
+rounded to the nearest nano-hertz. This is the schedule for the fundamental
+when the signal is actually sent on the n-th harmonic, so the tone spacing at
+the fundamental is 1/n of the nominal spacing.
+*/
+func (s Schedule) Divide(n int) Schedule {
+	r := s
+	r.Steps = make([]Step, len(s.Steps))
+	for i, step := range s.Steps {
+		step.Frequency = step.Frequency.MulDiv(1, uint64(n))
+		r.Steps[i] = step
+	}
+	return r
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSchedule(t *testing.T) {
+	symbols, err := WsprMessage("K1ABC", "FN42", 37)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := WSPR2.NextStart(time.Date(2025, 3, 1, 10, 0, 30, 0, time.UTC))
+	s, err := NewSchedule(symbols, 14_095_600*Hertz, 1500*Hertz, WSPR2, start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(s.Steps) != 162 || !s.End.Equal(start.Add(110592*time.Millisecond)) {
+		t.Errorf("got %d steps ending at %v", len(s.Steps), s.End)
+	}
+	for i, step := range s.Steps {
+		// half the tone spacing is 0.732421875 Hz so these are exact
+		want := 14_097_100*Hertz + Frequency(2*int(symbols[i])-3)*732_421_875
+		if step.Frequency != want || step.Tone != symbols[i] {
+			t.Errorf("step %d = %v, want %v", i, step, want)
+		}
+		if !step.Start.Equal(start.Add(WSPR2.SymbolOffset(i))) {
+			t.Errorf("step %d starts at %v", i, step.Start)
+		}
+	}
+	if d := s.Steps[81].Start.Sub(start); d != 55296*time.Millisecond {
+		t.Errorf("symbol 81 starts after %v", d)
+	}
+
+	slow, err := NewSchedule(symbols, 474_200*Hertz, 1500*Hertz, WSPR15, start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if slow.End.Sub(start) != 8*110592*time.Millisecond {
+		t.Errorf("WSPR-15 schedule ends after %v", slow.End.Sub(start))
+	}
+	// these tones are 0.091552734375 Hz apart so they are rounded to the nearest nano-hertz
+	offsets := []Frequency{-274_658_203, -91_552_734, 91_552_734, 274_658_203}
+	if f := slow.Steps[0].Frequency; f != 475_700*Hertz+offsets[symbols[0]] {
+		t.Errorf("WSPR-15 first tone %v", f)
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	symbols, _ := WsprMessage("K1ABC", "FN42", 37)
+	now := time.Now()
+	if _, err := NewSchedule(symbols[1:], 14*Megahertz, 1500*Hertz, WSPR2, now); err == nil {
+		t.Errorf("expected error for short message")
+	}
+	if _, err := NewSchedule(symbols, 14*Megahertz, 1500*Hertz, FST4W120, now); err == nil {
+		t.Errorf("expected error for mode mismatch")
+	}
+	if _, err := NewSchedule(symbols, 0, 1500*Hertz, WSPR2, now); err == nil {
+		t.Errorf("expected error for zero dial frequency")
+	}
+	bad := append([]int8{}, symbols...)
+	bad[10] = 4
+	if _, err := NewSchedule(bad, 14*Megahertz, 1500*Hertz, WSPR2, now); err == nil {
+		t.Errorf("expected error for bad tone")
+	}
+}
+
+func TestScheduleAt(t *testing.T) {
+	symbols, _ := WsprMessage("K1ABC", "FN42", 37)
+	start := time.Date(2025, 3, 1, 10, 0, 1, 0, time.UTC)
+	s, _ := NewSchedule(symbols, 14_095_600*Hertz, 1500*Hertz, WSPR2, start)
+	for i, step := range s.Steps {
+		for _, dt := range []time.Duration{0, 1, WSPR2.SymbolDuration() / 2} {
+			got, ok := s.At(step.Start.Add(dt))
+			if !ok || !got.Start.Equal(step.Start) {
+				t.Errorf("At(step %d + %v) got %v, %v", i, dt, got, ok)
+			}
+		}
+		got, ok := s.At(step.Start.Add(-1))
+		if i > 0 && (!ok || !got.Start.Equal(s.Steps[i-1].Start)) {
+			t.Errorf("At(step %d - 1ns) got %v, %v", i, got, ok)
+		}
+	}
+	if _, ok := s.At(start.Add(-1)); ok {
+		t.Errorf("expected nothing before the start")
+	}
+	if _, ok := s.At(s.End); ok {
+		t.Errorf("expected nothing at the end")
+	}
+}
+
+func TestScheduleDivide(t *testing.T) {
+	symbols, _ := WsprMessage("K1ABC", "FN42", 37)
+	start := time.Date(2025, 3, 1, 10, 0, 1, 0, time.UTC)
+	s, _ := NewSchedule(symbols, 432_300_000*Hertz, 1500*Hertz, WSPR2, start)
+	d := s.Divide(3)
+	if len(d.Steps) != len(s.Steps) || !d.End.Equal(s.End) {
+		t.Fatalf("divided schedule has %d steps ending at %v", len(d.Steps), d.End)
+	}
+	for i, step := range d.Steps {
+		if d := 3*step.Frequency - s.Steps[i].Frequency; d < -2 || d > 2 || !step.Start.Equal(s.Steps[i].Start) {
+			t.Errorf("step %d = %v, original %v", i, step, s.Steps[i])
+		}
+	}
+	// the original is untouched
+	if s.Steps[0].Frequency < 432*Megahertz {
+		t.Errorf("original schedule changed")
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+This file contains code to smooth the transitions between tones. Switching
+frequency abruptly at each symbol boundary spreads energy well outside the
+6 Hz that a WSPR signal needs. Since the synthesizer can be set with very fine
+resolution, we can instead sweep the frequency from one tone to the next over
+a short transition time which keeps the spectrum much tighter.
+*/
+
+package protocol
+
+import (
+	"math"
+	"time"
+)
+
+// Shape selects the curve used to move between tones
+type Shape int
+
+const (
+	// Linear ramps the frequency at a constant rate
+	Linear Shape = iota
+	// RaisedCosine follows half a cosine so the rate of change starts and ends at zero
+	RaisedCosine
+	// Gaussian is the step response of a Gaussian filter as used in GFSK
+	Gaussian
+)
+
+func (s Shape) String() string {
+	switch s {
+	case Linear:
+		return "linear"
+	case RaisedCosine:
+		return "raised-cosine"
+	case Gaussian:
+		return "gaussian"
+	default:
+		return "unknown"
+	}
+}
+
+/*
+Shaping describes how to smooth a schedule. Transition is the time taken to
+move between tones and is centred on the symbol boundary. A transition of zero
+gives abrupt changes. For the Gaussian shape, Transition is four standard
+deviations of the filter so that about 95% of the change happens inside it.
+Interval is the time between frequency updates.
+*/
+type Shaping struct {
+	Shape      Shape
+	Transition time.Duration
+	Interval   time.Duration
+}
+
+// Point is a frequency that should be set at a particular time
+type Point struct {
+	At        time.Time
+	Frequency Frequency
+}
+
+/*
+Shaped returns the frequency trajectory for a schedule sampled every
+shaping.Interval from the start of the first symbol up to the end of the
+transmission. The first and last tones are held flat up to the start and end.
+
+An error is returned if the interval isn't positive, the transition is
+negative or the transition is longer than a symbol.
+*/
+func (s Schedule) Shaped(shaping Shaping) ([]Point, error) {
+	if shaping.Interval <= 0 {
+		return nil, newFieldError("interval", shaping.Interval.String(), -1, "must be positive")
+	}
+	if shaping.Transition < 0 || shaping.Transition > s.Mode.SymbolDuration() {
+		return nil, newFieldError("transition", shaping.Transition.String(), -1, "must be between zero and one symbol")
+	}
+	if shaping.Shape < Linear || shaping.Shape > Gaussian {
+		return nil, newFieldError("shape", shaping.Shape.String(), -1, "unknown shape")
+	}
+	if len(s.Steps) == 0 {
+		return nil, nil
+	}
+
+	start := s.Steps[0].Start
+	n := int((s.End.Sub(start) + shaping.Interval - 1) / shaping.Interval)
+	points := make([]Point, 0, n)
+
+	// how far either side of a boundary a transition has any effect
+	reach := shaping.Transition / 2
+	if shaping.Shape == Gaussian {
+		reach = 2 * shaping.Transition
+	}
+
+	k := 0
+	for i := 0; i < n; i++ {
+		t := start.Add(time.Duration(i) * shaping.Interval)
+		// k is the first boundary that can still affect t
+		for k+1 < len(s.Steps) && t.Sub(s.Steps[k+1].Start) >= reach {
+			k++
+		}
+		f := s.Steps[k].Frequency
+		for j := k + 1; j < len(s.Steps); j++ {
+			dt := t.Sub(s.Steps[j].Start)
+			if dt <= -reach {
+				break
+			}
+			f += Frequency(math.Round(float64(s.Steps[j].Frequency-s.Steps[j-1].Frequency) * shaping.step(dt)))
+		}
+		points = append(points, Point{At: t, Frequency: f})
+	}
+	return points, nil
+}
+
+/*
+step gives the fraction of a transition completed at time dt relative to the
+boundary. This goes from 0 well before the boundary to 1 well after and is
+0.5 at the boundary itself.
+*/
+func (s Shaping) step(dt time.Duration) float64 {
+	if s.Transition == 0 {
+		if dt < 0 {
+			return 0
+		}
+		return 1
+	}
+	u := float64(dt)/float64(s.Transition) + 0.5
+	switch s.Shape {
+	case Gaussian:
+		sigma := 0.25
+		return 0.5 * (1 + math.Erf((u-0.5)/(sigma*math.Sqrt2)))
+	case RaisedCosine:
+		u = math.Max(0, math.Min(1, u))
+		return 0.5 * (1 - math.Cos(math.Pi*u))
+	default:
+		return math.Max(0, math.Min(1, u))
+	}
+}
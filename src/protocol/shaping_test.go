@@ -0,0 +1,126 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func testSchedule(t *testing.T) Schedule {
+	symbols, err := WsprMessage("K1ABC", "FN42", 37)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := NewSchedule(symbols, 14_095_600*Hertz, 1500*Hertz, WSPR2, time.Date(2025, 3, 1, 10, 0, 1, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestShapedAbrupt(t *testing.T) {
+	s := testSchedule(t)
+	points, err := s.Shaped(Shaping{Shape: Linear, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(points) != 11060 {
+		t.Errorf("got %d points", len(points))
+	}
+	for _, p := range points {
+		step, ok := s.At(p.At)
+		if !ok || step.Frequency != p.Frequency {
+			t.Fatalf("at %v got %v, want %v", p.At, p.Frequency, step.Frequency)
+		}
+	}
+}
+
+func TestShapedSmooth(t *testing.T) {
+	s := testSchedule(t)
+	spacing := WSPR2.ToneSpacing()
+	transition := 100 * time.Millisecond
+	interval := time.Millisecond
+	for _, shape := range []Shape{Linear, RaisedCosine, Gaussian} {
+		points, err := s.Shaped(Shaping{Shape: shape, Transition: transition, Interval: interval})
+		if err != nil {
+			t.Fatalf("%v unexpected error: %v", shape, err)
+		}
+
+		// the largest tone change is 3 spacings, and no shape is steeper than
+		// a Gaussian which peaks at 1.6 times the linear rate
+		limit := 1.6 * 3 * spacing * float64(interval) / float64(transition)
+		for i := 1; i < len(points); i++ {
+			if d := math.Abs((points[i].Frequency - points[i-1].Frequency).Hz()); d > limit {
+				t.Fatalf("%v jumps by %.4f Hz at point %d", shape, d, i)
+			}
+		}
+
+		for i, step := range s.Steps {
+			// the middle of each symbol is on the tone
+			mid := step.Start.Sub(s.Steps[0].Start) + WSPR2.SymbolDuration()/2
+			p := points[mid/interval]
+			if p.Frequency != step.Frequency {
+				t.Errorf("%v symbol %d centre got %v, want %v", shape, i, p.Frequency, step.Frequency)
+			}
+			// and half way through each transition is half way between tones
+			if i > 0 && step.Start.Sub(s.Steps[0].Start)%interval == 0 {
+				p := points[step.Start.Sub(s.Steps[0].Start)/interval]
+				want := (step.Frequency + s.Steps[i-1].Frequency) / 2
+				if d := p.Frequency - want; d < -1 || d > 1 {
+					t.Errorf("%v boundary %d got %v, want %v", shape, i, p.Frequency, want)
+				}
+			}
+		}
+	}
+}
+
+func TestShapingStep(t *testing.T) {
+	for _, shape := range []Shape{Linear, RaisedCosine, Gaussian} {
+		s := Shaping{Shape: shape, Transition: 100 * time.Millisecond}
+		prev := -1.0
+		for dt := -200 * time.Millisecond; dt <= 200*time.Millisecond; dt += time.Millisecond {
+			v := s.step(dt)
+			if v < prev || v < 0 || v > 1 {
+				t.Fatalf("%v step(%v) = %v after %v", shape, dt, v, prev)
+			}
+			// steps are symmetrical about the boundary
+			if math.Abs(v+s.step(-dt)-1) > 1e-12 {
+				t.Errorf("%v step is not symmetrical at %v", shape, dt)
+			}
+			prev = v
+		}
+		if s.step(-50*time.Millisecond) > 0.03 || s.step(50*time.Millisecond) < 0.97 {
+			t.Errorf("%v does most of its change outside the transition", shape)
+		}
+	}
+}
+
+func TestShapedErrors(t *testing.T) {
+	s := testSchedule(t)
+	for _, shaping := range []Shaping{
+		{Shape: Linear, Interval: 0},
+		{Shape: Linear, Interval: time.Millisecond, Transition: -1},
+		{Shape: Linear, Interval: time.Millisecond, Transition: time.Second},
+		{Shape: Shape(7), Interval: time.Millisecond},
+	} {
+		if _, err := s.Shaped(shaping); err == nil {
+			t.Errorf("expected error for %+v", shaping)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+This file contains code that implements WSPR Type 3 messages. These carry a
+6 character locator in the callsign field and a 15-bit hash of the call sign in
+the locator field. The power is stored as a negative number to mark the
+message type. A receiver can only show the call sign if it has previously seen
+a Type 1 or Type 2 message with a call sign that has the same hash.
+*/
+
+package protocol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/*
+WsprMessageType3 encodes a call sign hash, 6 character locator and power as a
+Type 3 message in the same form as WsprMessage.
+*/
+func WsprMessageType3(callsign, location string, power int) ([]int8, error) {
+	bits, err := PackType3(callsign, location, power)
+	if err != nil {
+		return nil, err
+	}
+	return channelSymbols(bits), nil
+}
+
+/*
+WsprMessagePair returns the two messages a beacon should alternate between to
+send a 6 character locator. The first message is a Type 1 message with the
+4 character locator or, for a compound call sign, a Type 2 message. The second
+is the Type 3 message with the full locator.
+*/
+func WsprMessagePair(callsign, location string, power int) ([][]int8, error) {
+	if len(location) != 6 {
+		return nil, newFieldError("locator", location, -1, "must have 6 characters")
+	}
+	var first []int8
+	var err error
+	if strings.Contains(callsign, "/") {
+		first, err = WsprMessageType2(callsign, power)
+	} else {
+		first, err = WsprMessage(callsign, location[:4], power)
+	}
+	if err != nil {
+		return nil, err
+	}
+	second, err := WsprMessageType3(callsign, location, power)
+	if err != nil {
+		return nil, err
+	}
+	return [][]int8{first, second}, nil
+}
+
+/*
+PackType3 encodes a Type 3 message into bits 55...6 in the same layout as
+PackBits. The locator is rotated by one character (FN42AX becomes N42AXF) so
+that it looks like a call sign and is encoded with CallSign. The hash of the
+call sign takes the place of the locator and the power is encoded as -(dBm+1).
+*/
+func PackType3(callsign, location string, power int) (uint64, error) {
+	if strings.TrimSpace(callsign) == "" {
+		return 0, newFieldError("callsign", callsign, -1, "must not be empty")
+	}
+	return packType3Hash(Hash(callsign), location, power)
+}
+
+// packType3Hash is PackType3 for when only the hash of the call sign is known
+func packType3Hash(hash uint32, location string, power int) (uint64, error) {
+	if err := checkLocator6(location); err != nil {
+		return 0, err
+	}
+	c, err := CallSign(location[1:] + location[:1])
+	if err != nil {
+		return 0, newFieldError("locator", location, -1, "invalid locator")
+	}
+	if !ValidPower(power) {
+		return 0, errIllegalPower(power)
+	}
+	return (c << 28) + (uint64(hash&0x7fff) << 13) + (Power(-(power + 1)) << 6), nil
+}
+
+/*
+unpackType3 decodes a Type 3 message. The call sign can't be recovered from
+the hash, so it is returned as "<...>" in the same way that WSJT-X shows an
+unknown hash.
+*/
+func unpackType3(bits uint64) (Message, error) {
+	power := -(UnpackPower((bits>>6)&0x7f) + 1)
+	if !ValidPower(power) {
+		return Message{}, newFieldError("power", strconv.Itoa(power), -1, "encoded value out of range")
+	}
+	rotated, err := UnpackCallSign(bits >> 28)
+	if err != nil {
+		return Message{}, err
+	}
+	if len(rotated) != 6 {
+		return Message{}, newFieldError("locator", rotated, -1, "encoded value is invalid")
+	}
+	locator := rotated[5:] + rotated[:5]
+	if err := checkLocator6(locator); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Type:     Type3,
+		CallSign: "<...>",
+		Locator:  locator,
+		Power:    power,
+		Hash:     uint32(bits>>13) & 0x7fff,
+	}, nil
+}
+
+// checkLocator6 verifies that a locator has a valid field, square and subsquare
+func checkLocator6(locator string) error {
+	if len(locator) != 6 {
+		return newFieldError("locator", locator, -1, "must have 6 characters")
+	}
+	if _, err := Locator(locator[:4]); err != nil {
+		var fe *FieldError
+		if errors.As(err, &fe) {
+			fe.Value = locator
+		}
+		return err
+	}
+	for i := 4; i < 6; i++ {
+		c := locator[i]
+		if !('A' <= c && c <= 'X') && !('a' <= c && c <= 'x') {
+			return newFieldError("locator", locator, i, "invalid character")
+		}
+	}
+	return nil
+}
+
+/*
+Hash computes the 15-bit call sign hash used by WSJT-X for Type 3 messages.
+This is Bob Jenkins' lookup3 hash of the upper case call sign with an initial
+value of 146.
+*/
+func Hash(callsign string) uint32 {
+	return lookup3([]byte(strings.ToUpper(callsign)), 146) & 0x7fff
+}
+
+/*
+lookup3 is the hashlittle function from Bob Jenkins' lookup3.c, processing the
+key a byte at a time.
+*/
+func lookup3(key []byte, initval uint32) uint32 {
+	a := 0xdeadbeef + uint32(len(key)) + initval
+	b, c := a, a
+
+	for len(key) > 12 {
+		a += uint32(key[0]) | uint32(key[1])<<8 | uint32(key[2])<<16 | uint32(key[3])<<24
+		b += uint32(key[4]) | uint32(key[5])<<8 | uint32(key[6])<<16 | uint32(key[7])<<24
+		c += uint32(key[8]) | uint32(key[9])<<8 | uint32(key[10])<<16 | uint32(key[11])<<24
+		a, b, c = lookup3Mix(a, b, c)
+		key = key[12:]
+	}
+	if len(key) == 0 {
+		return c
+	}
+
+	// the last block is zero padded
+	tail := [12]byte{}
+	copy(tail[:], key)
+	a += uint32(tail[0]) | uint32(tail[1])<<8 | uint32(tail[2])<<16 | uint32(tail[3])<<24
+	b += uint32(tail[4]) | uint32(tail[5])<<8 | uint32(tail[6])<<16 | uint32(tail[7])<<24
+	c += uint32(tail[8]) | uint32(tail[9])<<8 | uint32(tail[10])<<16 | uint32(tail[11])<<24
+	return lookup3Final(a, b, c)
+}
+
+func rot(x uint32, k int) uint32 {
+	return x<<k | x>>(32-k)
+}
+
+func lookup3Mix(a, b, c uint32) (uint32, uint32, uint32) {
+	a -= c
+	a ^= rot(c, 4)
+	c += b
+	b -= a
+	b ^= rot(a, 6)
+	a += c
+	c -= b
+	c ^= rot(b, 8)
+	b += a
+	a -= c
+	a ^= rot(c, 16)
+	c += b
+	b -= a
+	b ^= rot(a, 19)
+	a += c
+	c -= b
+	c ^= rot(b, 4)
+	b += a
+	return a, b, c
+}
+
+func lookup3Final(a, b, c uint32) uint32 {
+	c ^= b
+	c -= rot(b, 14)
+	a ^= c
+	a -= rot(c, 11)
+	b ^= a
+	b -= rot(a, 25)
+	c ^= b
+	c -= rot(b, 16)
+	a ^= c
+	a -= rot(c, 4)
+	b ^= a
+	b -= rot(a, 14)
+	c ^= b
+	c -= rot(b, 24)
+	return c
+}
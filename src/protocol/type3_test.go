@@ -0,0 +1,142 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package protocol
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLookup3(t *testing.T) {
+	// reference values from the driver in Bob Jenkins' lookup3.c
+	for _, test := range []struct {
+		key     string
+		initval uint32
+		want    uint32
+	}{
+		{"", 0, 0xdeadbeef},
+		{"Four score and seven years ago", 0, 0x17770551},
+		{"Four score and seven years ago", 1, 0xcd628161},
+	} {
+		if got := lookup3([]byte(test.key), test.initval); got != test.want {
+			t.Errorf("lookup3(%q, %d) = %x, want %x", test.key, test.initval, got, test.want)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	if Hash("k1abc") != Hash("K1ABC") {
+		t.Errorf("hash should not depend on case")
+	}
+	if Hash("K1ABC") == Hash("PJ4/K1ABC") {
+		t.Errorf("hash should include the prefix")
+	}
+	for _, call := range []string{"K1ABC", "PJ4/K1ABC", "HB9EGM", "W8ZLW/12"} {
+		if h := Hash(call); h >= 1<<15 {
+			t.Errorf("Hash(%q) = %d is more than 15 bits", call, h)
+		}
+	}
+}
+
+func TestPackType3(t *testing.T) {
+	grid, _ := CallSign("N42AXF")
+	want := grid<<28 + uint64(Hash("K1ABC"))<<13 + (0x40-38)<<6
+	got, err := PackType3("K1ABC", "FN42AX", 37)
+	if err != nil {
+		t.Fatalf("PackType3() unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("PackType3() = %x, want %x", got, want)
+	}
+
+	for _, test := range []struct {
+		callsign, locator string
+		power             int
+	}{
+		{"K1ABC", "FN42", 37},
+		{"K1ABC", "FN42AXB", 37},
+		{"K1ABC", "FN42AY", 37},
+		{"K1ABC", "SN42AX", 37},
+		{"K1ABC", "FNX2AX", 37},
+		{"K1ABC", "FN42AX", 35},
+		{"K1ABC", "FN42AX", 70},
+		{"", "FN42AX", 37},
+	} {
+		if _, err := PackType3(test.callsign, test.locator, test.power); err == nil {
+			t.Errorf("PackType3(%q, %q, %d) expected error", test.callsign, test.locator, test.power)
+		}
+	}
+}
+
+func TestType3RoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		callsign, locator string
+		power             int
+	}{
+		{"K1ABC", "FN42AX", 37},
+		{"PJ4/K1ABC", "FK52UD", 0},
+		{"HB9EGM", "JN36BK", 60},
+		{"W8ZLW", "AA00AA", 3},
+		{"W8ZLW", "RR99XX", 7},
+	} {
+		t.Run(test.callsign, func(t *testing.T) {
+			symbols, err := WsprMessageType3(test.callsign, test.locator, test.power)
+			if err != nil {
+				t.Fatalf("WsprMessageType3() unexpected error: %v", err)
+			}
+			bits, err := DecodeBits(symbols)
+			if err != nil {
+				t.Fatalf("DecodeBits() unexpected error: %v", err)
+			}
+			if h := uint32(bits>>13) & 0x7fff; h != Hash(test.callsign) {
+				t.Errorf("decoded hash %d, want %d", h, Hash(test.callsign))
+			}
+			call, loc, p, err := UnpackBits(bits)
+			if err != nil {
+				t.Fatalf("UnpackBits() unexpected error: %v", err)
+			}
+			if call != "<...>" || loc != test.locator || p != test.power {
+				t.Errorf("UnpackBits() got %q %q %d", call, loc, p)
+			}
+		})
+	}
+}
+
+func TestWsprMessagePair(t *testing.T) {
+	pair, err := WsprMessagePair("K1ABC", "FN42AX", 37)
+	if err != nil {
+		t.Fatalf("WsprMessagePair() unexpected error: %v", err)
+	}
+	type1, _ := WsprMessage("K1ABC", "FN42", 37)
+	type3, _ := WsprMessageType3("K1ABC", "FN42AX", 37)
+	if len(pair) != 2 || slices.Compare(pair[0], type1) != 0 || slices.Compare(pair[1], type3) != 0 {
+		t.Errorf("WsprMessagePair() doesn't give Type 1 and Type 3 messages")
+	}
+
+	pair, err = WsprMessagePair("PJ4/K1ABC", "FK52UD", 37)
+	if err != nil {
+		t.Fatalf("WsprMessagePair() unexpected error: %v", err)
+	}
+	call, loc, _, err := Decode(pair[0])
+	if err != nil || call != "PJ4/K1ABC" || loc != "" {
+		t.Errorf("first message should be Type 2, got %q %q %v", call, loc, err)
+	}
+
+	if _, err := WsprMessagePair("K1ABC", "FN42", 37); err == nil {
+		t.Errorf("expected error for short locator")
+	}
+}
@@ -22,6 +22,8 @@ package protocol
 
 import (
 	"errors"
+	"strconv"
+	"strings"
 )
 
 func WsprMessage(callsign, location string, power int) ([]int8, error) {
@@ -29,12 +31,20 @@ func WsprMessage(callsign, location string, power int) ([]int8, error) {
 	if err != nil {
 		return nil, err
 	}
+	return channelSymbols(bits), nil
+}
+
+/*
+channelSymbols turns 50 packed message bits into the 162 channel symbols by
+adding parity, interleaving and merging in the sync vector.
+*/
+func channelSymbols(bits uint64) []int8 {
 	message := Parity(bits)
 	message = interleave(message)
 	for i := 0; i < len(message); i++ {
 		message[i] = 2*message[i] + sync[i]
 	}
-	return message, nil
+	return message
 }
 
 /*
@@ -79,7 +89,8 @@ PackBits encodes a WSPR message as bits packed from the compressed form of
 the callsign, location and power. These resulting bits are in bits number 55...6
 which is the WSPR convention.
 
-An error is returned if there is an error encoding anything.
+An error is returned if there is an error encoding anything including a power
+that is not one of the legal WSPR levels (see ValidPower).
 */
 func PackBits(callsign, location string, power int) (uint64, error) {
 	c, err := CallSign(callsign)
@@ -90,9 +101,30 @@ func PackBits(callsign, location string, power int) (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if !ValidPower(power) {
+		return 0, errIllegalPower(power)
+	}
 	return (c << 28) + (l << 13) + (Power(power) << 6), nil
 }
 
+/*
+UnpackBits reverses PackBits by splitting bits 55...6 into the callsign, locator
+and power fields and decoding each of them. For every input accepted by
+PackBits, UnpackBits returns the same values with letters in upper case.
+
+Type 2 and Type 3 messages are also decoded, see UnpackMessage for details.
+
+An error is returned if any of the fields holds a value that PackBits could
+not have produced.
+*/
+func UnpackBits(bits uint64) (callsign, locator string, power int, err error) {
+	m, err := UnpackMessage(bits)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return m.CallSign, m.Locator, m.Power, nil
+}
+
 func Parity(message uint64) []int8 {
 	// these hold the 162 bits of output, one bit per byte
 	out := make([]int8, 162)
@@ -103,21 +135,21 @@ func Parity(message uint64) []int8 {
 	for i := 55; i >= 6; i-- {
 		bit := (message >> i) & 1
 		s1 = (s1 << 1) | uint32(bit)
-		out[k] = int8(parity32(s1 & 0xF2D05351))
+		out[k] = int8(parity32(s1 & poly1))
 		k++
 
 		s2 = (s2 << 1) | uint32(bit)
-		out[k] = int8(parity32(s1 & 0xE4613C47))
+		out[k] = int8(parity32(s1 & poly2))
 		k++
 	}
 	// these bottom bits are all zeros
 	for i := 0; i < 31; i++ {
 		s1 = s1 << 1
-		out[k] = int8(parity32(s1 & 0xF2D05351))
+		out[k] = int8(parity32(s1 & poly1))
 		k++
 
 		s2 = s2 << 1
-		out[k] = int8(parity32(s1 & 0xE4613C47))
+		out[k] = int8(parity32(s1 & poly2))
 		k++
 	}
 	return out
@@ -140,9 +172,10 @@ func parity32(input uint32) int {
 }
 
 /*
-CallSign converts a 5 or 6 character call sign to a uint64 using the very
+CallSign converts a call sign of up to 6 characters to a uint64 using the very
 idiosyncratic WSPR encoding. The call sign must match the regex
-`[A-Z0-9]?[A-Z0-9][0-9][A-Z ][A-Z ][A-Z ]`.
+`[A-Z0-9]?[A-Z0-9][0-9][A-Z]{0,3}`. As with WSJT-X, a digit in the third
+position takes precedence so that calls like E51ABC are not shifted right.
 */
 func CallSign(callsign string) (uint64, error) {
 	var err error
@@ -150,78 +183,141 @@ func CallSign(callsign string) (uint64, error) {
 	var encodeCount int
 	tail := 0
 	n := len(callsign)
-	if n >= 2 && isdigit(callsign[1]) {
-		// for example K1ABC
-		encoded, err = encodeChar(' ', ALPHA|DIGIT|SPACE, 0)
+	if n > 6 {
+		return 0, newFieldError("callsign", callsign, -1, "must be at most 6 characters")
+	}
+	if i := strings.IndexByte(callsign, ' '); i >= 0 {
+		return 0, newFieldError("callsign", callsign, i, "embedded space")
+	}
+	invalid := func(i int) error {
+		return newFieldError("callsign", callsign, i, "invalid character")
+	}
+	if n >= 3 && isdigit(callsign[2]) {
+		encoded, err = encodeChar(callsign[0], ALPHA|DIGIT|SPACE, 0)
 		if err != nil {
-			return 0, err
+			return 0, invalid(0)
 		}
-		encoded, err = encodeChar(callsign[0], ALPHA|DIGIT, encoded)
+		encoded, err = encodeChar(callsign[1], ALPHA|DIGIT, encoded)
 		if err != nil {
-			return 0, err
+			return 0, invalid(1)
 		}
-		encoded, err = encodeChar(callsign[1], DIGIT, encoded)
+		encoded, err = encodeChar(callsign[2], DIGIT, encoded)
 		if err != nil {
-			return 0, err
+			return 0, invalid(2)
 		}
-		tail = 2
-	} else if n >= 3 && isdigit(callsign[2]) {
-		encoded, err = encodeChar(callsign[0], ALPHA|DIGIT|SPACE, 0)
+		tail = 3
+	} else if n >= 2 && isdigit(callsign[1]) {
+		// for example K1ABC
+		if n > 5 {
+			return 0, newFieldError("callsign", callsign, 5, "too many characters after the digit")
+		}
+		encoded, err = encodeChar(' ', ALPHA|DIGIT|SPACE, 0)
 		if err != nil {
 			return 0, err
 		}
-		encoded, err = encodeChar(callsign[1], ALPHA|DIGIT, encoded)
+		encoded, err = encodeChar(callsign[0], ALPHA|DIGIT, encoded)
 		if err != nil {
-			return 0, err
+			return 0, invalid(0)
 		}
-		encoded, err = encodeChar(callsign[2], DIGIT, encoded)
+		encoded, err = encodeChar(callsign[1], DIGIT, encoded)
 		if err != nil {
-			return 0, err
+			return 0, invalid(1)
 		}
-		tail = 3
+		tail = 2
 	} else {
-		return 0, errors.New("ill-formed callsign, must start with {alpha}{alpha}?{digit}")
+		return 0, newFieldError("callsign", callsign, -1, "must start with {alpha}{alpha}?{digit}")
 	}
 	encodeCount = 3
 	for ; tail < len(callsign); tail++ {
 		encoded, err = encodeChar(callsign[tail], ALPHA|SPACE, encoded)
+		if err != nil {
+			return 0, invalid(tail)
+		}
 		encodeCount++
 	}
 	for ; encodeCount < 6; encodeCount++ {
-		encoded, err = encodeChar(callsign[tail], ALPHA|SPACE, encoded)
-		encodeCount++
+		encoded, err = encodeChar(' ', ALPHA|SPACE, encoded)
+		if err != nil {
+			return 0, err
+		}
 	}
 	return encoded, nil
 }
 
+// callChars lists the characters in the order used by the callsign encoding
+const callChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ "
+
+/*
+UnpackCallSign converts the 28-bit integer produced by CallSign back into text.
+The leading space used to align short call signs and any trailing padding is
+removed.
+*/
+func UnpackCallSign(encoded uint64) (string, error) {
+	if encoded >= 37*36*10*27*27*27 {
+		return "", newFieldError("callsign", strconv.FormatUint(encoded, 10), -1, "encoded value out of range")
+	}
+	call := [6]byte{}
+	for i := 5; i >= 3; i-- {
+		call[i] = callChars[10+encoded%27]
+		encoded /= 27
+	}
+	call[2] = callChars[encoded%10]
+	encoded /= 10
+	call[1] = callChars[encoded%36]
+	encoded /= 36
+	call[0] = callChars[encoded]
+	return strings.Trim(string(call[:]), " "), nil
+}
+
 /*
 Locator converts a four character Maidenhead location reference into a uin64
 using an interleaved encoding
 */
 func Locator(locator string) (uint64, error) {
 	if len(locator) != 4 {
-		return 0, errors.New("locator has wrong length")
+		return 0, newFieldError("locator", locator, -1, "must have 4 characters")
+	}
+	invalid := func(i int) error {
+		return newFieldError("locator", locator, i, "invalid character")
 	}
 	encoded, err := encodeChar(locator[0], MAIDENHEAD, 0)
 	if err != nil {
-		return 0, err
+		return 0, invalid(0)
 	}
 	encoded, err = encodeChar(locator[2], DIGIT, encoded)
 	if err != nil {
-		return 0, err
+		return 0, invalid(2)
 	}
 	encoded = 179 - encoded
 	encoded, err = encodeChar(locator[1], MAIDENHEAD, encoded)
 	if err != nil {
-		return 0, err
+		return 0, invalid(1)
 	}
 	encoded, err = encodeChar(locator[3], DIGIT, encoded)
 	if err != nil {
-		return 0, err
+		return 0, invalid(3)
 	}
 	return encoded, nil
 }
 
+/*
+UnpackLocator converts the 15-bit integer produced by Locator back into a four
+character Maidenhead locator.
+*/
+func UnpackLocator(encoded uint64) (string, error) {
+	if encoded >= 180*180 {
+		return "", newFieldError("locator", strconv.FormatUint(encoded, 10), -1, "encoded value out of range")
+	}
+	long := 179 - encoded/180
+	lat := encoded % 180
+	return string([]byte{
+		byte('A' + long/10),
+		byte('A' + lat/10),
+		byte('0' + long%10),
+		byte('0' + lat%10),
+	}), nil
+}
+
 /*
 Power encodes a power measured in dBm to the required bits
 */
@@ -229,6 +325,13 @@ func Power(dBm int) uint64 {
 	return uint64(0x40 + dBm)
 }
 
+/*
+UnpackPower converts the 7 power bits produced by Power back to dBm
+*/
+func UnpackPower(bits uint64) int {
+	return int(bits) - 0x40
+}
+
 func isdigit(b byte) bool {
 	return b >= '0' && b <= '9'
 }
@@ -279,29 +382,7 @@ func encodeChar(c byte, legalValues int, encoded uint64) (uint64, error) {
 		offset++
 	}
 	if v == 1000 {
-		return 0, errors.New("invalid character in callsign")
+		return 0, errors.New("invalid character")
 	}
 	return encoded*offset + v, nil
 }
-
-/*
-0 dBm = 0.001 W
- 3 0.002
- 7 0.005
-10 0.01
-13 0.02
-17 0.05
-20 0.1
-23 0.2
-27 0.5
-30 1
-33 2
-37 5
-40 10
-43 20
-47 50
-50 100
-53 200
-57 500
-60 1000
-*/
@@ -419,3 +419,134 @@ func Test_encodeChar(t *testing.T) {
 		})
 	}
 }
+
+func TestCallSign(t *testing.T) {
+	for _, test := range []struct {
+		callsign string
+		wantErr  bool
+	}{
+		{"K1ABC", false},
+		{"KA1ABC", false},
+		{"E51ABC", false},
+		{"K1A", false},
+		{"2E0AB", false},
+		{"W8ZLW", false},
+
+		{"K1ABCD", true},
+		{"KA1ABCD", true},
+		{"K1 AB", true},
+		{"K1A3C", true},
+		{"KAB", true},
+		{"K", true},
+		{"", true},
+	} {
+		t.Run(test.callsign, func(t *testing.T) {
+			encoded, err := CallSign(test.callsign)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("CallSign(%q) error = %v, wantErr %v", test.callsign, err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := UnpackCallSign(encoded)
+			if err != nil {
+				t.Fatalf("UnpackCallSign(%d) unexpected error: %v", encoded, err)
+			}
+			if got != test.callsign {
+				t.Errorf("UnpackCallSign(CallSign(%q)) = %q", test.callsign, got)
+			}
+		})
+	}
+}
+
+func TestCallSignRoundTrip(t *testing.T) {
+	letters := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	alnum := "0123456789" + letters
+	// a pseudo-random sample of legal call signs of each shape
+	k := 17
+	next := func(s string) byte {
+		k = (k*1103515245 + 12345) & 0x7fffffff
+		return s[k%len(s)]
+	}
+	for i := 0; i < 10_000; i++ {
+		call := []byte{}
+		if i%2 == 0 {
+			call = append(call, next(alnum))
+		}
+		call = append(call, next(alnum), next("0123456789"))
+		for j := 0; j < i%4; j++ {
+			call = append(call, next(letters))
+		}
+		encoded, err := CallSign(string(call))
+		if err != nil {
+			t.Fatalf("CallSign(%q) unexpected error: %v", call, err)
+		}
+		got, err := UnpackCallSign(encoded)
+		if err != nil {
+			t.Fatalf("UnpackCallSign(%d) unexpected error: %v", encoded, err)
+		}
+		if got != string(call) {
+			t.Fatalf("UnpackCallSign(CallSign(%q)) = %q", call, got)
+		}
+	}
+	if _, err := UnpackCallSign(37 * 36 * 10 * 27 * 27 * 27); err == nil {
+		t.Errorf("expected error for out of range callsign")
+	}
+}
+
+func TestLocatorRoundTrip(t *testing.T) {
+	for encoded := uint64(0); encoded < 180*180; encoded++ {
+		locator, err := UnpackLocator(encoded)
+		if err != nil {
+			t.Fatalf("UnpackLocator(%d) unexpected error: %v", encoded, err)
+		}
+		got, err := Locator(locator)
+		if err != nil {
+			t.Fatalf("Locator(%q) unexpected error: %v", locator, err)
+		}
+		if got != encoded {
+			t.Fatalf("Locator(UnpackLocator(%d)) = %d", encoded, got)
+		}
+	}
+	if _, err := UnpackLocator(180 * 180); err == nil {
+		t.Errorf("expected error for out of range locator")
+	}
+	got, err := UnpackLocator(22632)
+	if err != nil || got != "FN42" {
+		t.Errorf("UnpackLocator(22632) = %q, %v", got, err)
+	}
+}
+
+func TestUnpackBits(t *testing.T) {
+	for _, input := range []string{"K1ABC FN42 37", "HB9EGM JN36 30", "W8ZLW AA00 3", "e51abc rr99 0"} {
+		t.Run(input, func(t *testing.T) {
+			s := strings.Split(input, " ")
+			power, err := strconv.ParseInt(s[2], 10, 0)
+			if err != nil {
+				t.Fatalf("broken test: %e", err)
+			}
+			bits, err := PackBits(s[0], s[1], int(power))
+			if err != nil {
+				t.Fatalf("PackBits() unexpected error: %v", err)
+			}
+			call, loc, p, err := UnpackBits(bits)
+			if err != nil {
+				t.Fatalf("UnpackBits() unexpected error: %v", err)
+			}
+			if call != strings.ToUpper(s[0]) || loc != strings.ToUpper(s[1]) || p != int(power) {
+				t.Errorf("UnpackBits() got %q %q %d", call, loc, p)
+			}
+		})
+	}
+	for dBm := 0; dBm <= 60; dBm++ {
+		if got := UnpackPower(Power(dBm)); got != dBm {
+			t.Errorf("UnpackPower(Power(%d)) = %d", dBm, got)
+		}
+	}
+	if _, err := PackBits("K1ABC", "FN42", 61); err == nil {
+		t.Errorf("expected error for excessive power")
+	}
+	if _, err := PackBits("K1ABC", "FN42", -1); err == nil {
+		t.Errorf("expected error for negative power")
+	}
+}
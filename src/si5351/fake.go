@@ -0,0 +1,70 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package si5351
+
+import (
+	"fmt"
+)
+
+// Write records one register write transaction on a FakeBus
+type Write struct {
+	Address uint8
+	Data    []byte
+}
+
+/*
+FakeBus is an in-memory Si5351 for testing. Registers holds the state of the
+chip and Writes logs every write transaction. Like the real chip, the PLL reset
+register always reads as zero.
+*/
+type FakeBus struct {
+	Address   uint16
+	Registers [256]byte
+	Writes    []Write
+}
+
+// NewFakeBus returns a fake device at DefaultAddress
+func NewFakeBus() *FakeBus {
+	return &FakeBus{Address: DefaultAddress}
+}
+
+// Tx implements Bus
+func (b *FakeBus) Tx(addr uint16, w, r []byte) error {
+	if addr != b.Address {
+		return fmt.Errorf("fake si5351: no device at address %#x", addr)
+	}
+	if len(w) == 0 {
+		return nil
+	}
+	reg := int(w[0])
+	data := w[1:]
+	if reg+len(data) > len(b.Registers) || reg+len(r) > len(b.Registers) {
+		return fmt.Errorf("fake si5351: access past register 255")
+	}
+	if len(data) > 0 {
+		b.Writes = append(b.Writes, Write{Address: uint8(reg), Data: append([]byte{}, data...)})
+		copy(b.Registers[reg:], data)
+		b.Registers[regPLLReset] = 0
+	}
+	copy(r, b.Registers[reg:])
+	return nil
+}
+
+// Reset clears the log of writes
+func (b *FakeBus) Reset() {
+	b.Writes = nil
+}
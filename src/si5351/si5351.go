@@ -0,0 +1,280 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package si5351 is a register level driver for the Si5351 clock generator. It
+applies the full fractional configurations computed by support.Si5351Config
+and keeps a copy of the registers it has written so that retuning only sends
+the registers that actually change. That matters when stepping through the
+tones of a WSPR transmission since usually only a few bytes of one divider
+change at a time.
+
+The driver only needs a Bus which machine.I2C satisfies in TinyGo. FakeBus
+provides an in-memory chip for testing on a normal host.
+*/
+package si5351
+
+import (
+	"errors"
+	"fmt"
+	"wspr/src/support"
+)
+
+const (
+	// DefaultAddress is the I2C address with the ADDR pin low
+	DefaultAddress = 0x60
+
+	regStatus         = 0
+	regOutputDisable  = 3
+	regClk0Control    = 16
+	regSpreadSpectrum = 149
+	regPLLReset       = 177
+	regCrystalLoad    = 183
+
+	statusSysInit     = 0x80
+	clkPowerDown      = 0x80
+	clkDriveMask      = 0x03
+	spreadSpectrumOn  = 0x80
+	pllAReset         = 0x20
+	pllBReset         = 0x80
+	crystalLoad10pF   = 0xd2
+	maxRegisterWrites = 24
+)
+
+/*
+Bus is the part of an I2C bus that the driver needs. Tx writes w to the device
+at addr and then reads len(r) bytes into r. This matches machine.I2C.
+*/
+type Bus interface {
+	Tx(addr uint16, w, r []byte) error
+}
+
+// Drive is the output drive strength
+type Drive uint8
+
+const (
+	Drive2mA Drive = iota
+	Drive4mA
+	Drive6mA
+	Drive8mA
+)
+
+/*
+Device is an Si5351 on an I2C bus. Registers that have been read or written
+are remembered so that later changes only write the bytes that differ.
+*/
+type Device struct {
+	bus     Bus
+	Address uint16
+	drive   [8]Drive
+	shadow  [256]byte
+	known   [256]bool
+}
+
+// New returns a driver for a device at DefaultAddress with 8 mA drive on all outputs
+func New(bus Bus) *Device {
+	d := &Device{bus: bus, Address: DefaultAddress}
+	for i := range d.drive {
+		d.drive[i] = Drive8mA
+	}
+	return d
+}
+
+// Connected returns true if the device responds on the bus
+func (d *Device) Connected() (bool, error) {
+	if _, err := d.read(regStatus); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+/*
+Init puts the device in a known state with all outputs disabled and powered
+down, the crystal load set to 10 pF and spread spectrum off. An error is
+returned if the device hasn't finished its own power up initialization.
+*/
+func (d *Device) Init() error {
+	status, err := d.read(regStatus)
+	if err != nil {
+		return err
+	}
+	if status&statusSysInit != 0 {
+		return errors.New("si5351: device is still initializing")
+	}
+	d.known = [256]bool{}
+	if err := d.write(regOutputDisable, []byte{0xff}); err != nil {
+		return err
+	}
+	off := [8]byte{}
+	for i := range off {
+		off[i] = clkPowerDown
+	}
+	if err := d.write(regClk0Control, off[:]); err != nil {
+		return err
+	}
+	if err := d.write(regCrystalLoad, []byte{crystalLoad10pF}); err != nil {
+		return err
+	}
+	return d.update(regSpreadSpectrum, 0, spreadSpectrumOn)
+}
+
+/*
+Apply sets up a PLL and output according to a configuration. Only registers
+that differ from what the driver last wrote are sent, so applying a sequence of
+nearby frequencies usually writes just a few bytes of one divider. The output
+keeps the drive strength set with SetDrive.
+
+Apply doesn't reset the PLL. Small changes such as tone steps don't need it,
+but ResetPLL should be called after a PLL is first set up or changed a lot.
+*/
+func (d *Device) Apply(c support.Si5351Config, pll support.PLL, output int) error {
+	regs, err := c.Registers(pll, output)
+	if err != nil {
+		return err
+	}
+	var changes [maxRegisterWrites]support.Register
+	n := 0
+	for _, r := range regs {
+		if int(r.Address) == regClk0Control+output {
+			r.Value = r.Value&^clkDriveMask | byte(d.drive[output])
+		}
+		v := r.Value
+		if r.Mask != 0xff {
+			current, err := d.read(r.Address)
+			if err != nil {
+				return err
+			}
+			v = current&^r.Mask | r.Value&r.Mask
+		}
+		if d.known[r.Address] && d.shadow[r.Address] == v {
+			continue
+		}
+		if n == len(changes) {
+			return errors.New("si5351: too many register changes")
+		}
+		changes[n] = support.Register{Address: r.Address, Value: v, Mask: 0xff}
+		n++
+	}
+
+	// send runs of adjacent registers in one transaction
+	var buf [maxRegisterWrites]byte
+	for i := 0; i < n; {
+		j := i + 1
+		for j < n && changes[j].Address == changes[j-1].Address+1 {
+			j++
+		}
+		for k := i; k < j; k++ {
+			buf[k-i] = changes[k].Value
+		}
+		if err := d.write(changes[i].Address, buf[:j-i]); err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+/*
+ResetPLL does a soft reset of one or both PLLs. This is needed after a PLL is
+first configured and keeps the phase relationship between outputs that share
+a PLL.
+*/
+func (d *Device) ResetPLL(plls ...support.PLL) error {
+	v := byte(0)
+	for _, p := range plls {
+		switch p {
+		case support.PLLA:
+			v |= pllAReset
+		case support.PLLB:
+			v |= pllBReset
+		default:
+			return fmt.Errorf("si5351: invalid PLL %d", p)
+		}
+	}
+	// the reset bits clear themselves so don't remember them
+	err := d.write(regPLLReset, []byte{v})
+	d.known[regPLLReset] = false
+	return err
+}
+
+// EnableOutput turns an output on or off
+func (d *Device) EnableOutput(output int, enable bool) error {
+	if output < 0 || output > 7 {
+		return fmt.Errorf("si5351: invalid output %d", output)
+	}
+	v := byte(1) << output
+	if enable {
+		v = 0
+	}
+	return d.update(regOutputDisable, v, 1<<output)
+}
+
+// SetDrive sets the drive strength of an output
+func (d *Device) SetDrive(output int, drive Drive) error {
+	if output < 0 || output > 7 {
+		return fmt.Errorf("si5351: invalid output %d", output)
+	}
+	if drive > Drive8mA {
+		return fmt.Errorf("si5351: invalid drive %d", drive)
+	}
+	d.drive[output] = drive
+	return d.update(uint8(regClk0Control+output), byte(drive), clkDriveMask)
+}
+
+// update changes the bits of a register selected by mask
+func (d *Device) update(address uint8, value, mask byte) error {
+	current, err := d.read(address)
+	if err != nil {
+		return err
+	}
+	v := current&^mask | value&mask
+	if v == current {
+		return nil
+	}
+	return d.write(address, []byte{v})
+}
+
+// read returns a register, from the shadow copy if possible
+func (d *Device) read(address uint8) (byte, error) {
+	if d.known[address] && address != regStatus {
+		return d.shadow[address], nil
+	}
+	var r [1]byte
+	if err := d.bus.Tx(d.Address, []byte{address}, r[:]); err != nil {
+		return 0, err
+	}
+	d.shadow[address] = r[0]
+	d.known[address] = address != regStatus
+	return r[0], nil
+}
+
+// write sends values to consecutive registers and remembers them
+func (d *Device) write(address uint8, values []byte) error {
+	var buf [maxRegisterWrites + 1]byte
+	if len(values) > maxRegisterWrites {
+		return errors.New("si5351: write too long")
+	}
+	buf[0] = address
+	copy(buf[1:], values)
+	if err := d.bus.Tx(d.Address, buf[:len(values)+1], nil); err != nil {
+		return err
+	}
+	for i, v := range values {
+		d.shadow[int(address)+i] = v
+		d.known[int(address)+i] = true
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package si5351
+
+import (
+	"testing"
+	"wspr/src/protocol"
+	"wspr/src/support"
+)
+
+func newTestDevice(t *testing.T) (*Device, *FakeBus) {
+	bus := NewFakeBus()
+	d := New(bus)
+	if ok, err := d.Connected(); !ok || err != nil {
+		t.Fatalf("not connected: %v", err)
+	}
+	if err := d.Init(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bus.Reset()
+	return d, bus
+}
+
+func config(t *testing.T, f float64) support.Si5351Config {
+	c, err := support.New(25*protocol.Megahertz, 0, protocol.FromHz(f))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return c
+}
+
+func TestInit(t *testing.T) {
+	bus := NewFakeBus()
+	bus.Registers[regSpreadSpectrum] = 0x85
+	d := New(bus)
+	if err := d.Init(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bus.Registers[regOutputDisable] != 0xff || bus.Registers[regCrystalLoad] != crystalLoad10pF ||
+		bus.Registers[regSpreadSpectrum] != 0x05 {
+		t.Errorf("registers after init % x", bus.Registers[:])
+	}
+	for i := 0; i < 8; i++ {
+		if bus.Registers[regClk0Control+i] != clkPowerDown {
+			t.Errorf("output %d not powered down", i)
+		}
+	}
+
+	bus.Registers[regStatus] = statusSysInit
+	if err := d.Init(); err == nil {
+		t.Errorf("expected error while device initializes")
+	}
+	if ok, err := New(&FakeBus{Address: 0x61}).Connected(); ok || err == nil {
+		t.Errorf("expected no device")
+	}
+}
+
+func TestApply(t *testing.T) {
+	d, bus := newTestDevice(t)
+	c := config(t, 14_097_100)
+	if err := d.Apply(c, support.PLLA, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	regs, _ := c.Registers(support.PLLA, 1)
+	for _, r := range regs {
+		if bus.Registers[r.Address]&r.Mask != r.Value {
+			t.Errorf("register %d = %02x, want %02x", r.Address, bus.Registers[r.Address], r.Value)
+		}
+	}
+
+	// the same configuration again writes nothing
+	bus.Reset()
+	if err := d.Apply(c, support.PLLA, 1); err != nil || len(bus.Writes) != 0 {
+		t.Errorf("repeat apply wrote %v, %v", bus.Writes, err)
+	}
+
+	// the next tone up only touches the MultiSynth
+	if err := d.Apply(config(t, 14_097_100+1.4648), support.PLLA, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bus.Writes) == 0 {
+		t.Fatalf("no registers written")
+	}
+	for _, w := range bus.Writes {
+		if w.Address < 50 || int(w.Address)+len(w.Data) > 58 {
+			t.Errorf("unexpected write %+v", w)
+		}
+	}
+}
+
+func TestApplySharedRegisters(t *testing.T) {
+	d, bus := newTestDevice(t)
+	// output 6 on PLL B must leave the PLL A integer flag alone
+	bus.Registers[22] = 0x40
+	bus.Registers[92] = 0x30
+	d.known = [256]bool{}
+	if err := d.Apply(config(t, 10e6), support.PLLB, 6); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bus.Registers[22] != 0x6f || bus.Registers[23]&0x40 == 0 || bus.Registers[90] != 80 || bus.Registers[92] != 0x30 {
+		t.Errorf("got 22=%02x 23=%02x 90=%d 92=%02x", bus.Registers[22], bus.Registers[23], bus.Registers[90], bus.Registers[92])
+	}
+}
+
+func TestResetAndOutputs(t *testing.T) {
+	d, bus := newTestDevice(t)
+	if err := d.ResetPLL(support.PLLA); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.ResetPLL(support.PLLA, support.PLLB); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bus.Writes) != 2 || bus.Writes[0].Data[0] != pllAReset || bus.Writes[1].Data[0] != pllAReset|pllBReset {
+		t.Errorf("reset writes %v", bus.Writes)
+	}
+	if err := d.ResetPLL(support.PLL(3)); err == nil {
+		t.Errorf("expected error for bad PLL")
+	}
+
+	if err := d.EnableOutput(2, true); err != nil || bus.Registers[regOutputDisable] != 0xfb {
+		t.Errorf("enable got %02x, %v", bus.Registers[regOutputDisable], err)
+	}
+	if err := d.EnableOutput(0, true); err != nil || bus.Registers[regOutputDisable] != 0xfa {
+		t.Errorf("enable got %02x, %v", bus.Registers[regOutputDisable], err)
+	}
+	if err := d.EnableOutput(2, false); err != nil || bus.Registers[regOutputDisable] != 0xfe {
+		t.Errorf("disable got %02x, %v", bus.Registers[regOutputDisable], err)
+	}
+	if err := d.EnableOutput(8, true); err == nil {
+		t.Errorf("expected error for bad output")
+	}
+
+	// drive strength is kept when a configuration is applied
+	if err := d.SetDrive(0, Drive2mA); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Apply(config(t, 7_040_100), support.PLLA, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bus.Registers[regClk0Control]&clkDriveMask != byte(Drive2mA) || bus.Registers[regClk0Control]&clkPowerDown != 0 {
+		t.Errorf("control register %02x", bus.Registers[regClk0Control])
+	}
+	if err := d.SetDrive(0, Drive6mA); err != nil || bus.Registers[regClk0Control]&clkDriveMask != byte(Drive6mA) {
+		t.Errorf("control register %02x, %v", bus.Registers[regClk0Control], err)
+	}
+	if err := d.SetDrive(0, Drive(4)); err == nil {
+		t.Errorf("expected error for bad drive")
+	}
+}
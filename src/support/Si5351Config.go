@@ -20,21 +20,25 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"math/bits"
+	"wspr/src/protocol"
 )
 
 type Si5351Config struct {
-	f0, pll, f                float64 // clock, pll and output frequencies
-	a0, b0, c0, a1, b1, c1, r uint32  // chip parameters
-	eps                       float64 // error in output frequency (Hz)
+	f0, pll, f                protocol.Frequency // clock, pll and output frequencies
+	a0, b0, c0, a1, b1, c1, r uint32             // chip parameters
+	target                    protocol.Frequency // requested output frequency
+	strategy                  Strategy           // how the dividers were chosen
 }
 
 /*
 Si5351Config computes configuration parameters for the PLL and multi-synth
 fractional dividers in a Si5351 clock generator.
 
-The parameter `f0` is the internal clock frequency (in Hz) for the generator (typically
-25 or 27MHz), `pll` is the PLL frequency (in Hz) in the range of 600..900MHz, `f` is the
-desired output frequency (in Hz). If `pll` is zero, then a suitable value will be
+The parameter `f0` is the internal clock frequency for the generator (typically
+25 or 27MHz), `pll` is the PLL frequency in the range of 600..900MHz, `f` is the
+desired output frequency. If `pll` is zero, then a suitable value will be
 chosen.
 
 The output will be values such that f0 * (a0 + b0/c0) / (a1 + b1/c1) to within as
@@ -43,72 +47,170 @@ small a tolerance as possible.
 An error is returned if the routine cannot find good parameters for the dividers
 or if the input is invalid.
 */
-func New(f0, pll, f float64) (Si5351Config, error) {
+func New(f0, pll, f protocol.Frequency) (Si5351Config, error) {
 	//if f < 3700 {
 	//	return Si5351Config{}, errors.New("f too small")
 	//}
-	if f0 < 10e6 || f0 > 27e6 {
+	if f0 < 10*protocol.Megahertz || f0 > 40*protocol.Megahertz {
 		return Si5351Config{}, errors.New("Si5351Config: invalid clock frequency")
 	}
 
-	if f > 200e6 {
+	if f > maxOutput {
 		return Si5351Config{}, errors.New("Si5351Config: output frequency > 200MHz")
 	}
+	if f <= 0 {
+		return Si5351Config{}, errors.New("Si5351Config: output frequency must be positive")
+	}
 
-	if f > 150e6 {
+	if f > minDivBy4Output {
 		pll = 4 * f
-	} else if f >= 100e6 {
+	} else if f >= 100*protocol.Megahertz {
 		pll = 6 * f
 	} else if pll == 0 {
-		if f < 5e6 {
-			pll = 600e6
+		if f < 5*protocol.Megahertz {
+			pll = 600 * protocol.Megahertz
 		} else {
-			pll = 800e6
+			pll = 800 * protocol.Megahertz
 		}
-	} else if pll < 600e6 || pll > 900e6 {
+	} else if pll < pllMin || pll > pllMax {
 		return Si5351Config{}, errors.New("si5351Config: pll is out of range")
 	}
-	z := pll / f0
-	if z < 15 {
+	if pll < 15*f0 {
 		return Si5351Config{}, errors.New("Si5351Config: can't happen, feedback ratio too small")
 	}
-	if z > 90 {
+	if pll > 90*f0 {
 		return Si5351Config{}, errors.New("Si5351Config: can't happen, feedback ratio too big")
 	}
-	b, c, _ := NearestFraction(uint64(z*1e12), 1_000_000_000_000, 1<<20)
-	r := Si5351Config{
-		f0:  f0,
-		pll: pll,
-		f:   f,
-		a0:  uint32(b / c),
-		b0:  uint32(b % c),
-		c0:  uint32(c),
-	}
+	r := Si5351Config{f0: f0, target: f, strategy: Split}
+	r.a0, r.b0, r.c0 = fraction(ratio(pll, f0))
 
-	z = f0 * (float64(r.a0) + float64(r.b0)/float64(r.c0)) / f
-	if !near(z, 4, 1e-9) && !near(z, 6, 1e-9) && z < 8 {
-		return Si5351Config{}, fmt.Errorf("Si5351Config: output multi-synth ratio too small: %.5g %v", z-6, r)
+	num, den := msRatio(f0, r.a0, r.b0, r.c0, f)
+	if x, _ := new(big.Rat).SetFrac(num, den).Float64(); !near(x, 4, 1e-9) && !near(x, 6, 1e-9) && x < 8 {
+		return Si5351Config{}, fmt.Errorf("Si5351Config: output multi-synth ratio too small: %.5g %v", x-6, r)
 	}
 	r.r = 1
-	for z/float64(r.r) > 2048 && r.r <= 128 {
+	for num.Cmp(new(big.Int).Mul(den, big.NewInt(2048*int64(r.r)))) > 0 && r.r <= 128 {
 		r.r = r.r * 2
 	}
 	if r.r > 128 {
 		return Si5351Config{}, errors.New("Si5351Config: output divider ratio too big, f_out too low")
 	}
-	b, c, _ = NearestFraction(uint64(z*1e12/float64(r.r)), 1_000_000_000_000, 1<<20)
-	r.a1 = uint32(b / c)
-	r.b1 = uint32(b % c)
-	r.c1 = uint32(c)
+	r.a1, r.b1, r.c1 = fraction(num, den.Mul(den, big.NewInt(int64(r.r))))
 
-	r.f = f0 * (float64(r.a0) + float64(r.b0)/float64(r.c0)) / (float64(r.a1) + float64(r.b1)/float64(r.c1))
-	if math.Abs(r.eps)/f > 1e-12 {
-		return Si5351Config{}, errors.New("si5351Config: frequency error is out of range")
-	}
-	r.eps = f - r.f
+	r.settle()
 	return r, nil
 }
 
 func near(a float64, b float64, eps float64) bool {
 	return math.Abs(a-b) <= eps
 }
+
+/*
+settle works out the PLL and output frequencies exactly from the divider
+settings, rounding each to the nearest nano-hertz. A configuration with a zero
+denominator produces nothing.
+*/
+func (c *Si5351Config) settle() {
+	c.pll, c.f = 0, 0
+	if c.c0 == 0 || c.c1 == 0 || c.r == 0 {
+		return
+	}
+	pllNum := uint64(c.a0)*uint64(c.c0) + uint64(c.b0)
+	msNum := uint64(c.a1)*uint64(c.c1) + uint64(c.b1)
+	c.pll = c.f0.MulDiv(pllNum, uint64(c.c0))
+
+	// decoded denominators can be up to 2^27 so these products may not fit
+	hi, num := bits.Mul64(pllNum, uint64(c.c1))
+	hi0, den := bits.Mul64(uint64(c.c0), msNum)
+	hi1, den := bits.Mul64(den, uint64(c.r))
+	if hi == 0 && hi0 == 0 && hi1 == 0 {
+		c.f = c.f0.MulDiv(num, den)
+		return
+	}
+	n := new(big.Int).SetUint64(pllNum)
+	n.Mul(n, new(big.Int).SetUint64(uint64(c.c1)))
+	n.Mul(n, big.NewInt(int64(c.f0)))
+	d := new(big.Int).SetUint64(uint64(c.c0))
+	d.Mul(d, new(big.Int).SetUint64(msNum))
+	d.Mul(d, new(big.Int).SetUint64(uint64(c.r)))
+	// round to the nearest nano-hertz
+	n.Add(n.Lsh(n, 1), d)
+	n.Quo(n, d.Lsh(d, 1))
+	if !n.IsInt64() {
+		c.f = protocol.MaxFrequency
+		return
+	}
+	c.f = protocol.Frequency(n.Int64())
+}
+
+// Frequency returns the output frequency that this configuration actually produces
+func (c Si5351Config) Frequency() protocol.Frequency {
+	return c.f
+}
+
+// PLLFrequency returns the frequency of the PLL
+func (c Si5351Config) PLLFrequency() protocol.Frequency {
+	return c.pll
+}
+
+// Error returns the requested frequency minus the frequency actually produced
+func (c Si5351Config) Error() protocol.Frequency {
+	return c.target - c.f
+}
+
+/*
+Resolution returns the smallest step that the output can be moved from
+this setting by changing the divider that does the fine tuning. Since both the
+numerator and denominator can change, this is set by the nearest fraction
+with a denominator that still fits in the registers.
+*/
+func (c Si5351Config) Resolution() protocol.Frequency {
+	ms := float64(c.a1) + float64(c.b1)/float64(c.c1)
+	if c.Strategy() == FinePLL {
+		return protocol.FromHz(c.f0.Hz() * fareyGap(uint64(c.b0), uint64(c.c0), maxDenominator) / ms / float64(c.r))
+	}
+	return protocol.FromHz(c.f.Hz() / ms * fareyGap(uint64(c.b1), uint64(c.c1), maxDenominator))
+}
+
+/*
+fareyGap returns the distance from b/c to the closest other fraction with a
+denominator no more than n. The neighbours p/q of b/c satisfy b*q - c*p = ±1
+so q is fixed modulo c and the closest neighbour has the largest such q.
+*/
+func fareyGap(b, c, n uint64) float64 {
+	g := gcd(b, c)
+	b, c = b/g, c/g
+	if c == 1 {
+		return 1 / float64(n)
+	}
+	inv := modInverse(b%c, c)
+	q := uint64(0)
+	for _, r := range []uint64{inv, c - inv} {
+		if x := r + (n-r)/c*c; x > q {
+			q = x
+		}
+	}
+	return 1 / float64(c) / float64(q)
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// modInverse returns x with a*x = 1 mod m, for a and m relatively prime
+func modInverse(a, m uint64) uint64 {
+	r0, r1 := int64(m), int64(a)
+	t0, t1 := int64(0), int64(1)
+	for r1 != 0 {
+		k := r0 / r1
+		r0, r1 = r1, r0-k*r1
+		t0, t1 = t1, t0-k*t1
+	}
+	if t0 < 0 {
+		t0 += int64(m)
+	}
+	return uint64(t0)
+}
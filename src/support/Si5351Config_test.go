@@ -19,6 +19,7 @@ package support
 import (
 	"math"
 	"testing"
+	"wspr/src/protocol"
 )
 
 var seed = int64(1)
@@ -45,12 +46,12 @@ func Test_accuracy(t *testing.T) {
 	}
 	for i := 0; i < len(frequencies); i++ {
 		for f := frequencies[i][0]; f <= frequencies[i][1]; f += rand() * 0.2 {
-			config, err := New(25e6, 0.0, f)
+			config, err := New(25*protocol.Megahertz, 0, protocol.FromHz(f))
 			if err != nil {
 				t.Errorf("Error in si5351Config: %s", err)
 			}
-			if math.Abs(config.eps)/f > 1e-9 {
-				t.Errorf("Big discrepancy: %.4f, %.2f vs %.2f", config.eps, config.f, f)
+			if math.Abs(config.Error().Hz())/f > 1e-9 {
+				t.Errorf("Big discrepancy: %v, %v vs %.2f", config.Error(), config.f, f)
 			}
 		}
 	}
@@ -58,18 +59,39 @@ func Test_accuracy(t *testing.T) {
 
 func Test_range(t *testing.T) {
 	for f := 1.0; f < 2300; f += 50 {
-		_, err := New(25e6, 0.0, f)
+		_, err := New(25*protocol.Megahertz, 0, protocol.FromHz(f))
 		if err == nil {
 			t.Errorf("Expected error in si5351Config due to low frequency: %.3f", f)
 		}
 	}
 	for f := 2302.0; f < 200e6; f *= 1.2 {
-		r, err := New(25e6, 0.0, f)
+		r, err := New(25*protocol.Megahertz, 0, protocol.FromHz(f))
 		if err != nil {
 			t.Errorf("Error in si5351Config: %s", err)
 		}
-		if r.eps > 1e-3 {
-			t.Errorf("Error in si5351Config: %.3f", r.eps)
+		if r.Error() > protocol.MilliHertz {
+			t.Errorf("Error in si5351Config: %v", r.Error())
+		}
+	}
+}
+
+func Test_fareyGap(t *testing.T) {
+	// compare with a brute force search over all fractions
+	const n = 60
+	for c := uint64(1); c <= n; c++ {
+		for b := uint64(0); b < c; b++ {
+			x := float64(b) / float64(c)
+			want := 1.0
+			for q := uint64(1); q <= n; q++ {
+				for p := uint64(0); p <= q; p++ {
+					if d := math.Abs(float64(p)/float64(q) - x); d > 1e-12 && d < want {
+						want = d
+					}
+				}
+			}
+			if got := fareyGap(b, c, n); math.Abs(got-want) > 1e-12 {
+				t.Errorf("%d/%d: got %g, want %g", b, c, got, want)
+			}
 		}
 	}
 }
@@ -16,6 +16,11 @@
 
 package support
 
+import (
+	"math"
+	"math/big"
+)
+
 /*
 Finds the best approximation c/d ≈ a/b such that d < max_denominator
 
@@ -90,3 +95,100 @@ func continuedFraction(a, b, e, f, max_denominator uint64) (c, d uint64) {
 		}
 	}
 }
+
+/*
+NearestRat is like NearestFraction but works on an exact rational target. This
+avoids going through float64 which only holds about 15 digits, so a target
+like 144,490,500.146484375 Hz can be used as is. Large ratios can't overflow.
+
+Returns the approximation c/d with d <= max_denominator and the exact error
+x - c/d. The target must not be negative and max_denominator must be at least
+1, since no fraction would fit otherwise. NearestRat panics if it is zero.
+*/
+func NearestRat(x *big.Rat, max_denominator uint64) (approx, eps *big.Rat) {
+	if max_denominator == 0 {
+		panic("NearestRat: max_denominator must be at least 1")
+	}
+	limit := new(big.Int).SetUint64(max_denominator)
+	// h/k are successive convergents, starting with 1/0 and 0/1
+	h0, h1 := big.NewInt(0), big.NewInt(1)
+	k0, k1 := big.NewInt(1), big.NewInt(0)
+	a := new(big.Int).Set(x.Num())
+	b := new(big.Int).Set(x.Denom())
+	term, rem, tmp := new(big.Int), new(big.Int), new(big.Int)
+	for b.Sign() != 0 {
+		term.QuoRem(a, b, rem)
+		k := new(big.Int).Add(tmp.Mul(term, k1), k0)
+		if k.Cmp(limit) > 0 {
+			break
+		}
+		h := new(big.Int).Add(tmp.Mul(term, h1), h0)
+		h0, h1 = h1, h
+		k0, k1 = k1, k
+		a, b = b, new(big.Int).Set(rem)
+	}
+	approx = new(big.Rat).SetFrac(h1, k1)
+	eps = new(big.Rat).Sub(x, approx)
+	return approx, eps
+}
+
+/*
+BestFraction finds the closest fraction c/d to a/b with d <= max_denominator
+and c <= max_numerator. A max_numerator of zero means no limit on the
+numerator.
+
+NearestFraction stops at the last convergent of the continued fraction that
+fits, but the closest fraction can be a semiconvergent that uses only part of
+the next term. Walking down the continued fraction keeps the target between
+the last convergent and the semiconvergents built on it, so when a term
+doesn't fully fit, the answer is whichever is closer of the last convergent
+and the largest semiconvergent that meets both bounds. Ties go to the smaller
+denominator.
+
+Returns c, d and the error a/b - c/d as floating point.
+*/
+func BestFraction(a, b, max_denominator, max_numerator uint64) (c, d uint64, eps float64) {
+	if max_numerator == 0 {
+		max_numerator = math.MaxUint64
+	}
+	x, y := a, b
+	// h1/k1 is the current convergent and h0/k0 the one before, starting
+	// with the conventional 1/0 and 0/1
+	h0, k0 := uint64(0), uint64(1)
+	h1, k1 := uint64(1), uint64(0)
+	for y != 0 {
+		term := x / y
+		t := term
+		if k1 != 0 && (max_denominator-k0)/k1 < t {
+			t = (max_denominator - k0) / k1
+		}
+		if h1 != 0 && (max_numerator-h0)/h1 < t {
+			t = (max_numerator - h0) / h1
+		}
+		if t < term {
+			hs, ks := h0+t*h1, k0+t*k1
+			if k1 == 0 || closer(a, b, hs, ks, h1, k1) {
+				h1, k1 = hs, ks
+			}
+			break
+		}
+		h0, h1 = h1, term*h1+h0
+		k0, k1 = k1, term*k1+k0
+		x, y = y, x-term*y
+	}
+	eps = float64(a)/float64(b) - float64(h1)/float64(k1)
+	return h1, k1, eps
+}
+
+// closer returns true if p1/q1 is strictly closer to a/b than p2/q2
+func closer(a, b, p1, q1, p2, q2 uint64) bool {
+	dist := func(p, q, other uint64) *big.Int {
+		// |a*q - b*p| * other is the distance scaled by b*q*other
+		d := new(big.Int).Mul(new(big.Int).SetUint64(a), new(big.Int).SetUint64(q))
+		d.Sub(d, new(big.Int).Mul(new(big.Int).SetUint64(b), new(big.Int).SetUint64(p)))
+		d.Abs(d)
+		return d.Mul(d, new(big.Int).SetUint64(other))
+	}
+	d1, d2 := dist(p1, q1, q2), dist(p2, q2, q1)
+	return d1.Cmp(d2) < 0 || (d1.Cmp(d2) == 0 && q1 < q2)
+}
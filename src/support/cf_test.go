@@ -202,3 +202,105 @@ func Test_generator(t *testing.T) {
 		}
 	}
 }
+
+func Test_nearestRat(t *testing.T) {
+	// same answers as NearestFraction when the target fits
+	for _, test := range [][3]uint64{
+		{314159265358, 100_000_000_000, 1000},
+		{2301, 500, 97},
+		{451, 98, 99},
+		{63, 9, 10},
+		{0, 1, 100},
+	} {
+		c, d, _ := NearestFraction(test[0], test[1], test[2])
+		approx, eps := NearestRat(new(big.Rat).SetFrac64(int64(test[0]), int64(test[1])), test[2])
+		if approx.Cmp(big.NewRat(int64(c), int64(d))) != 0 {
+			t.Errorf("%d/%d: got %v, want %d/%d", test[0], test[1], approx, c, d)
+		}
+		x := new(big.Rat).SetFrac64(int64(test[0]), int64(test[1]))
+		if new(big.Rat).Add(approx, eps).Cmp(x) != 0 {
+			t.Errorf("%d/%d: error %v is not exact", test[0], test[1], eps)
+		}
+	}
+
+	// the 2m WSPR tone 1 frequency can't be written exactly as a float64 ratio
+	f, _ := new(big.Rat).SetString("144490500.146484375")
+	z := new(big.Rat).Quo(new(big.Rat).Mul(f, big.NewRat(6, 1)), big.NewRat(25_000_000, 1))
+	approx, eps := NearestRat(z, maxDenominator)
+	if approx.Denom().Uint64() > maxDenominator || new(big.Rat).Add(approx, eps).Cmp(z) != 0 {
+		t.Errorf("got %v with error %v", approx, eps)
+	}
+	// about 10 µHz at the output
+	if e, _ := eps.Float64(); math.Abs(e) > 1e-11 {
+		t.Errorf("error %g", e)
+	}
+
+	// far too big for uint64
+	huge, _ := new(big.Rat).SetString("1000000000000000000000000000000/7")
+	if approx, eps := NearestRat(huge, 10); approx.Cmp(huge) != 0 || eps.Sign() != 0 {
+		t.Errorf("got %v, %v", approx, eps)
+	}
+
+	// the smallest limit gives the nearest integer below and zero is refused
+	if approx, _ := NearestRat(big.NewRat(7, 2), 1); approx.Cmp(big.NewRat(3, 1)) != 0 {
+		t.Errorf("got %v", approx)
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected a panic for a zero max_denominator")
+		}
+	}()
+	NearestRat(big.NewRat(7, 2), 0)
+}
+
+func Test_bestFraction(t *testing.T) {
+	// brute force search over all fractions within the bounds
+	brute := func(a, b, maxD, maxN uint64) (uint64, uint64) {
+		bestP, bestQ := uint64(0), uint64(0)
+		for q := uint64(1); q <= maxD; q++ {
+			p0 := a * q / b
+			for _, p := range []uint64{p0, p0 + 1} {
+				if maxN != 0 && p > maxN {
+					p = maxN
+				}
+				if bestQ == 0 || closer(a, b, p, q, bestP, bestQ) {
+					bestP, bestQ = p, q
+				}
+			}
+		}
+		return bestP, bestQ
+	}
+	for i := 0; i < 2000; i++ {
+		b := 1 + uint64(rand()*1000)
+		a := uint64(rand() * 5 * float64(b))
+		maxD := 1 + uint64(rand()*40)
+		maxN := uint64(rand() * 60)
+		c, d, _ := BestFraction(a, b, maxD, maxN)
+		p, q := brute(a, b, maxD, maxN)
+		if d == 0 || d > maxD || (maxN != 0 && c > maxN) {
+			t.Fatalf("%d/%d bounds %d, %d: got %d/%d out of bounds", a, b, maxD, maxN, c, d)
+		}
+		if closer(a, b, p, q, c, d) || closer(a, b, c, d, p, q) {
+			t.Errorf("%d/%d bounds %d, %d: got %d/%d, brute force %d/%d", a, b, maxD, maxN, c, d, p, q)
+		}
+		// never worse than the plain continued fraction
+		if maxN == 0 {
+			c1, d1, _ := NearestFraction(a, b, maxD)
+			if closer(a, b, c1, d1, c, d) {
+				t.Errorf("%d/%d: NearestFraction %d/%d beats %d/%d", a, b, c1, d1, c, d)
+			}
+		}
+	}
+
+	// a semiconvergent wins here: 333/106 doesn't fit so NearestFraction
+	// stops at 22/7, but 311/99 is closer to pi
+	c, d, _ := BestFraction(314159265358, 100_000_000_000, 105, 0)
+	c1, d1, _ := NearestFraction(314159265358, 100_000_000_000, 105)
+	if c != 311 || d != 99 || c1 != 22 || d1 != 7 {
+		t.Errorf("got %d/%d and %d/%d", c, d, c1, d1)
+	}
+	// the same again with the numerator doing the limiting
+	if c, d, _ := BestFraction(314159265358, 100_000_000_000, 1000, 330); c != 311 || d != 99 {
+		t.Errorf("got %d/%d", c, d)
+	}
+}
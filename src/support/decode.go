@@ -0,0 +1,336 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"fmt"
+	"sort"
+	"wspr/src/protocol"
+)
+
+/*
+Reading back the registers is often the quickest way to find out what a
+misbehaving board is really doing. The decoder here goes the other way from
+Registers and turns a register image back into PLL multipliers, MultiSynth
+ratios, R dividers and clock sources for each output.
+*/
+
+const (
+	// register 3 has one disable bit per output
+	regOutputDisable = 3
+	// bits in the CLKn control registers for the clock source
+	clkSrcMask = 0x0c
+	// bits in the MultiSynth block for the R divider
+	msRDivMask = 0x70
+)
+
+// ClockSource is where an output gets its clock, from bits 3:2 of the CLKn control register
+type ClockSource int
+
+const (
+	SourceXtal    ClockSource = iota // the crystal, bypassing the PLLs
+	SourceClkin                      // the CLKIN pin
+	SourceMSGroup                    // MultiSynth 0 for outputs 0-3 or MultiSynth 4 for outputs 4-7
+	SourceMS                         // the output's own MultiSynth
+)
+
+func (s ClockSource) String() string {
+	switch s {
+	case SourceXtal:
+		return "XTAL"
+	case SourceClkin:
+		return "CLKIN"
+	case SourceMSGroup:
+		return "MS0/MS4"
+	case SourceMS:
+		return "MS"
+	}
+	return fmt.Sprintf("ClockSource(%d)", int(s))
+}
+
+// RegisterImage holds register values by address. Registers that weren't read are missing.
+type RegisterImage map[uint8]byte
+
+// ImageFromBytes returns the image for a register dump that starts at address 0
+func ImageFromBytes(b []byte) RegisterImage {
+	image := RegisterImage{}
+	for i, v := range b {
+		if i > 255 {
+			break
+		}
+		image[uint8(i)] = v
+	}
+	return image
+}
+
+// block returns the 8 registers starting at base, or false if any of them is missing
+func (image RegisterImage) block(base int) ([8]byte, bool) {
+	var r [8]byte
+	for i := range r {
+		v, ok := image[uint8(base+i)]
+		if !ok {
+			return r, false
+		}
+		r[i] = v
+	}
+	return r, true
+}
+
+/*
+OutputState is one output as decoded from the registers. Config holds the
+divider settings and gives the output frequency for a reference at the f0
+passed to DecodeRegisters. An output that is driven straight from the crystal
+gets unit dividers so that Config still gives its frequency. The frequency of
+CLKIN isn't known so outputs driven from it have a zero frequency.
+*/
+type OutputState struct {
+	Output   int
+	Enabled  bool // powered up and not disabled by register 3
+	Inverted bool
+	Integer  bool // the MultiSynth is in integer mode, which outputs 6 and 7 always are
+	Source   ClockSource
+	PLL      PLL
+	Config   Si5351Config
+}
+
+// Frequency returns the frequency of the output
+func (o OutputState) Frequency() protocol.Frequency {
+	return o.Config.Frequency()
+}
+
+func (o OutputState) String() string {
+	state := "off"
+	if o.Enabled {
+		state = "on"
+	}
+	c := o.Config
+	switch o.Source {
+	case SourceXtal, SourceClkin:
+		return fmt.Sprintf("CLK%d %s: %v from %v / %d", o.Output, state, o.Frequency(), o.Source, c.r)
+	}
+	return fmt.Sprintf("CLK%d %s: %v from %v %v = %d + %d/%d, %v %d + %d/%d, R %d",
+		o.Output, state, o.Frequency(), o.PLL, c.PLLFrequency(), c.a0, c.b0, c.c0, o.Source, c.a1, c.b1, c.c1, c.r)
+}
+
+/*
+DecodeRegisters decodes the outputs in a register image using f0 as the
+frequency of the reference that drives the PLLs. Outputs whose registers are
+missing from the image are left out, so a partial image gives just the outputs
+that it covers. A powered down output that was never set up decodes with zero
+dividers, but an error is returned if an enabled output has a zero
+denominator or divider.
+*/
+func DecodeRegisters(f0 protocol.Frequency, image RegisterImage) ([]OutputState, error) {
+	var out []OutputState
+	for n := 0; n < 8; n++ {
+		s, ok, err := decodeOutput(f0, image, n)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// decodeOutput decodes output n, returning false if the image doesn't have its registers
+func decodeOutput(f0 protocol.Frequency, image RegisterImage, n int) (OutputState, bool, error) {
+	control, ok := image[uint8(regClk0Control+n)]
+	if !ok {
+		return OutputState{}, false, nil
+	}
+	s := OutputState{
+		Output:   n,
+		Enabled:  control&clkPowerDown == 0 && image[regOutputDisable]&(1<<n) == 0,
+		Inverted: control&clkInvert != 0,
+		Integer:  n >= 6 || control&clkMSInt != 0,
+		Source:   ClockSource(control & clkSrcMask >> 2),
+	}
+	r, ok := decodeR(image, n)
+	if !ok {
+		return OutputState{}, false, nil
+	}
+	c := Si5351Config{f0: f0, r: r}
+
+	switch s.Source {
+	case SourceXtal:
+		c.a0, c.c0, c.a1, c.c1 = 1, 1, 1, 1
+	case SourceClkin:
+		// nothing to compute from
+	default:
+		ms := n
+		if s.Source == SourceMSGroup {
+			ms = n / 4 * 4
+		}
+		// the PLL is picked by the control register of the MultiSynth, not the output
+		msControl, ok := image[uint8(regClk0Control+ms)]
+		if !ok {
+			return OutputState{}, false, nil
+		}
+		if msControl&clkMSSrcPLLB != 0 {
+			s.PLL = PLLB
+		}
+		block, ok := image.block(regPLLA + 8*int(s.PLL))
+		if !ok {
+			return OutputState{}, false, nil
+		}
+		a1, b1, c1, present, valid := decodeMS(image, ms)
+		if !present {
+			return OutputState{}, false, nil
+		}
+		a0, b0, c0, pllValid := blockDivider(block)
+		if !valid || !pllValid {
+			if s.Enabled {
+				return OutputState{}, false, fmt.Errorf("Si5351Config: output %d is enabled but %v or MultiSynth %d has a zero divider", n, s.PLL, ms)
+			}
+			break
+		}
+		c.a0, c.b0, c.c0 = a0, b0, c0
+		c.a1, c.b1, c.c1 = a1, b1, c1
+	}
+
+	c.settle()
+	c.target = c.f
+	c.strategy = c.guessStrategy()
+	s.Config = c
+	return s, true, nil
+}
+
+// decodeR returns the R divider ratio for output n
+func decodeR(image RegisterImage, n int) (uint32, bool) {
+	if n >= 6 {
+		v, ok := image[regR67]
+		return 1 << (v >> (4 * (n - 6)) & 0x07), ok
+	}
+	v, ok := image[uint8(regMS0+8*n+2)]
+	return 1 << (v & msRDivMask >> 4), ok
+}
+
+/*
+decodeMS returns the ratio of MultiSynth n. The first flag is false if the
+registers are missing and the second is false if they hold a zero divider.
+MultiSynths 6 and 7 are even integers held in a single register.
+*/
+func decodeMS(image RegisterImage, n int) (a, b, c uint32, present, valid bool) {
+	if n >= 6 {
+		v, ok := image[uint8(regMS6+n-6)]
+		return uint32(v), 0, 1, ok, v != 0
+	}
+	block, ok := image.block(regMS0 + 8*n)
+	if !ok {
+		return 0, 0, 0, false, false
+	}
+	if block[2]&msDivBy4 == msDivBy4 {
+		return 4, 0, 1, true, true
+	}
+	a, b, c, valid = blockDivider(block)
+	return a, b, c, true, valid
+}
+
+/*
+blockDivider unpacks P1, P2 and P3 from the 8 register layout and converts
+them back to a + b/c. Since a + b/c = (P1 + 512 + P2/P3) / 128, this is exact
+even for values that dividerParams would never produce, in which case the
+denominator can be up to 128 times P3. The result is false if P3 is zero.
+*/
+func blockDivider(block [8]byte) (a, b, c uint32, ok bool) {
+	p1 := uint64(block[2]&0x03)<<16 | uint64(block[3])<<8 | uint64(block[4])
+	p2 := uint64(block[5]&0x0f)<<16 | uint64(block[6])<<8 | uint64(block[7])
+	p3 := uint64(block[5]>>4)<<16 | uint64(block[0])<<8 | uint64(block[1])
+	if p3 == 0 {
+		return 0, 0, 0, false
+	}
+	x := p1 + 512
+	num := p2 + p3*(x%128)
+	den := 128 * p3
+	g := gcd(num, den)
+	num, den = num/g, den/g
+	return uint32(x/128 + num/den), uint32(num % den), uint32(den), true
+}
+
+// RegisterChange is a register with different values in two images
+type RegisterChange struct {
+	Address  uint8
+	Old, New byte
+}
+
+func (r RegisterChange) String() string {
+	return fmt.Sprintf("%d (%s): 0x%02x -> 0x%02x", r.Address, registerName(r.Address), r.Old, r.New)
+}
+
+// OutputChange is an output that decodes differently in two images
+type OutputChange struct {
+	Old, New OutputState
+}
+
+/*
+DiffImages compares two register images. The first result has the registers
+that are in both images but have different values, in address order. The
+second has the outputs that decode differently, which shows what the changed
+registers actually do. Registers and outputs that are only in one of the
+images are not compared.
+*/
+func DiffImages(f0 protocol.Frequency, a, b RegisterImage) ([]RegisterChange, []OutputChange, error) {
+	var regs []RegisterChange
+	for address, old := range a {
+		if v, ok := b[address]; ok && v != old {
+			regs = append(regs, RegisterChange{address, old, v})
+		}
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i].Address < regs[j].Address })
+
+	before, err := DecodeRegisters(f0, a)
+	if err != nil {
+		return nil, nil, err
+	}
+	after, err := DecodeRegisters(f0, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	var outputs []OutputChange
+	for _, old := range before {
+		for _, s := range after {
+			if s.Output == old.Output && s != old {
+				outputs = append(outputs, OutputChange{old, s})
+			}
+		}
+	}
+	return regs, outputs, nil
+}
+
+// registerName describes what a register is for
+func registerName(address uint8) string {
+	a := int(address)
+	switch {
+	case a == regOutputDisable:
+		return "output disable"
+	case a >= regClk0Control && a < regClk0Control+8:
+		return fmt.Sprintf("CLK%d control", a-regClk0Control)
+	case a >= regPLLA && a < regPLLB:
+		return fmt.Sprintf("PLLA+%d", a-regPLLA)
+	case a >= regPLLB && a < regMS0:
+		return fmt.Sprintf("PLLB+%d", a-regPLLB)
+	case a >= regMS0 && a < regMS6:
+		return fmt.Sprintf("MS%d+%d", (a-regMS0)/8, (a-regMS0)%8)
+	case a == regMS6 || a == regMS7:
+		return fmt.Sprintf("MS%d", a-regMS6+6)
+	case a == regR67:
+		return "R6/R7"
+	}
+	return "other"
+}
@@ -0,0 +1,274 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"wspr/src/protocol"
+)
+
+// planImage returns the register image for a frequency plan
+func planImage(t *testing.T, plan FrequencyPlan) [256]byte {
+	var regs [256]byte
+	for _, out := range plan.Outputs {
+		rs, err := out.Config.Registers(out.PLL, out.Output)
+		if err != nil {
+			t.Fatalf("output %d: %v", out.Output, err)
+		}
+		for _, r := range rs {
+			regs[r.Address] = regs[r.Address]&^r.Mask | r.Value
+		}
+	}
+	return regs
+}
+
+func Test_decodeRegisters(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		variant Variant
+		specs   []OutputSpec
+	}{
+		{
+			name:    "three",
+			variant: Si5351A,
+			specs: []OutputSpec{
+				{Frequency: 144_490_500 * protocol.Hertz, FineTune: true},
+				{Frequency: 10 * protocol.Megahertz, Integer: true},
+				{Frequency: 27_000_001 * protocol.Hertz},
+			},
+		},
+		{
+			name:    "eight",
+			variant: Si5351C,
+			specs: []OutputSpec{
+				{Frequency: 7_040_100 * protocol.Hertz, FineTune: true},
+				{Frequency: 10 * protocol.Megahertz, Integer: true},
+				{Frequency: 12_345_678 * protocol.Hertz},
+				{Frequency: 3_300 * protocol.Kilohertz},
+				{Frequency: 48 * protocol.Megahertz},
+				{Frequency: 100 * protocol.Kilohertz},
+				{Frequency: 20 * protocol.Megahertz},
+				{Frequency: 5 * protocol.Megahertz},
+			},
+		},
+		{
+			name:    "divby4",
+			variant: Si5351A,
+			specs:   []OutputSpec{{Frequency: 180 * protocol.Megahertz}, {Frequency: 30 * protocol.Megahertz, Integer: true}},
+		},
+	} {
+		plan, err := PlanOutputs(25*protocol.Megahertz, test.variant, test.specs)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		regs := planImage(t, plan)
+		outputs, err := DecodeRegisters(25*protocol.Megahertz, ImageFromBytes(regs[:]))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		// outputs that weren't set up look like enabled crystal outputs
+		if len(outputs) != 8 {
+			t.Fatalf("%s: decoded %d outputs", test.name, len(outputs))
+		}
+		for _, out := range plan.Outputs {
+			s := outputs[out.Output]
+			if s.Output != out.Output || !s.Enabled || s.Source != SourceMS || s.PLL != out.PLL {
+				t.Errorf("%s: decoded %v", test.name, s)
+			}
+			if s.Frequency() != out.Config.Frequency() || s.Config.PLLFrequency() != out.Config.PLLFrequency() {
+				t.Errorf("%s: decoded %v, want %v", test.name, s, out.Config.Frequency())
+			}
+			if s.Integer != out.Config.msInteger() || s.Config.r != out.Config.r {
+				t.Errorf("%s: output %d integer %v, R %d", test.name, out.Output, s.Integer, s.Config.r)
+			}
+		}
+	}
+}
+
+func Test_decodePartial(t *testing.T) {
+	c, err := New(25*protocol.Megahertz, 0, 14_097_100*protocol.Hertz)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rs, err := c.Registers(PLLB, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	image := RegisterImage{}
+	for _, r := range rs {
+		image[r.Address] = r.Value
+	}
+	outputs, err := DecodeRegisters(25*protocol.Megahertz, image)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(outputs) != 1 || outputs[0].Output != 2 || outputs[0].PLL != PLLB || outputs[0].Frequency() != c.Frequency() {
+		t.Fatalf("decoded %v", outputs)
+	}
+	if outputs[0].Config.a0 != c.a0 || outputs[0].Config.b0*c.c0 != c.b0*outputs[0].Config.c0 {
+		t.Errorf("PLL %d + %d/%d, want %d + %d/%d",
+			outputs[0].Config.a0, outputs[0].Config.b0, outputs[0].Config.c0, c.a0, c.b0, c.c0)
+	}
+
+	// without the PLL registers there is nothing to decode
+	for a := regPLLB; a < regPLLB+8; a++ {
+		delete(image, uint8(a))
+	}
+	if outputs, err := DecodeRegisters(25*protocol.Megahertz, image); err != nil || len(outputs) != 0 {
+		t.Errorf("decoded %v, %v", outputs, err)
+	}
+}
+
+func Test_decodeSources(t *testing.T) {
+	var regs [256]byte
+	for i := 0; i < 8; i++ {
+		regs[regClk0Control+i] = clkPowerDown
+	}
+	// CLK0 from the crystal divided by 4, inverted
+	regs[regClk0Control] = clkInvert | clkDrive8mA
+	regs[regMS0+2] = 2 << 4
+	// CLK1 from MultiSynth 0 which is left at 30 + 1/128 of PLL A
+	regs[regClk0Control+1] = 0x08 | clkDrive8mA
+	copy(regs[regPLLA:], []byte{0x00, 0x01, 0x00, 0x0e, 0x00, 0x00, 0x00, 0x00})
+	copy(regs[regMS0:], []byte{0x00, 0x01, 0x20, 0x0d, 0x00, 0x00, 0x00, 0x01})
+	// CLK2 from CLKIN
+	regs[regClk0Control+2] = 0x04 | clkDrive8mA
+	// CLK7 from MultiSynth 7 = 8 on PLL B with R7 = 2, but disabled in register 3
+	regs[regClk0Control+7] = clkSrcMS | clkMSSrcPLLB
+	copy(regs[regPLLB:], []byte{0x00, 0x01, 0x00, 0x0c, 0x00, 0x00, 0x00, 0x00})
+	regs[regMS7] = 8
+	regs[regR67] = 0x10
+	regs[regOutputDisable] = 0x80
+
+	outputs, err := DecodeRegisters(25*protocol.Megahertz, ImageFromBytes(regs[:]))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, test := range []struct {
+		output  int
+		enabled bool
+		source  ClockSource
+		f       protocol.Frequency
+	}{
+		{0, true, SourceXtal, 6_250 * protocol.Kilohertz},
+		// 800 MHz / (30 + 1/128) with R1 = 1
+		{1, true, SourceMSGroup, 26_659_724_030_200_469},
+		{2, true, SourceClkin, 0},
+		{3, false, SourceXtal, 25 * protocol.Megahertz},
+		{7, false, SourceMS, 700 * protocol.Megahertz / 16},
+	} {
+		s := outputs[test.output]
+		if s.Enabled != test.enabled || s.Source != test.source || s.Frequency() != test.f {
+			t.Errorf("CLK%d: got %v, want %v %v", test.output, s, test.source, test.f)
+		}
+	}
+	if !outputs[0].Inverted || outputs[1].Inverted || outputs[7].PLL != PLLB || !outputs[7].Integer {
+		t.Errorf("decoded %v", outputs)
+	}
+
+	// an enabled output can't have a zero divider
+	regs[regClk0Control+4] = clkSrcMS
+	if _, err := DecodeRegisters(25*protocol.Megahertz, ImageFromBytes(regs[:])); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func Test_decodeLargeDenominators(t *testing.T) {
+	// P2 and P3 that dividerParams would never produce give denominators near 2^27
+	pll := [8]byte{0xff, 0xff, 0x00, 0x0e, 0x00, 0xf7, 0xff, 0xff}
+	ms := [8]byte{0xff, 0xfd, 0x03, 0x82, 0x00, 0xf3, 0x21, 0x07}
+	a0, b0, c0, ok0 := blockDivider(pll)
+	a1, b1, c1, ok1 := blockDivider(ms)
+	if !ok0 || !ok1 || c0 < 1<<26 || c1 < 1<<26 {
+		t.Fatalf("got %d + %d/%d and %d + %d/%d", a0, b0, c0, a1, b1, c1)
+	}
+	c := Si5351Config{f0: 25 * protocol.Megahertz, a0: a0, b0: b0, c0: c0, a1: a1, b1: b1, c1: c1, r: 128}
+	c.settle()
+
+	exact := big.NewRat(int64(a0)*int64(c0)+int64(b0), int64(c0))
+	exact.Mul(exact, big.NewRat(int64(c1), (int64(a1)*int64(c1)+int64(b1))*128))
+	exact.Mul(exact, (25 * protocol.Megahertz).Rat())
+	got := new(big.Rat).Sub(exact, c.f.Rat())
+	if got.Abs(got).Cmp(big.NewRat(1, 2_000_000_000)) > 0 {
+		t.Errorf("got %v, want %v Hz", c.f, exact.FloatString(9))
+	}
+}
+
+func Test_blockDivider(t *testing.T) {
+	for _, test := range []struct {
+		a, b, c uint32
+	}{
+		{34, 16943, 25000},
+		{32, 0, 1},
+		{90, 1048574, 1048575},
+		{8, 1, 3},
+	} {
+		p1, p2, p3 := dividerParams(test.a, test.b, test.c)
+		a, b, c, ok := blockDivider(dividerRegisters(p1, p2, p3, 3))
+		if !ok || a != test.a || uint64(b)*uint64(test.c) != uint64(test.b)*uint64(c) {
+			t.Errorf("%d + %d/%d came back as %d + %d/%d", test.a, test.b, test.c, a, b, c)
+		}
+	}
+	// a P2 that dividerParams would never produce
+	if a, b, c, _ := blockDivider(dividerRegisters(128*30-512, 1, 1, 0)); a != 30 || b != 1 || c != 128 {
+		t.Errorf("got %d + %d/%d", a, b, c)
+	}
+	if _, _, _, ok := blockDivider([8]byte{}); ok {
+		t.Errorf("expected zero P3 to fail")
+	}
+}
+
+func Test_diffImages(t *testing.T) {
+	plan, err := PlanOutputs(25*protocol.Megahertz, Si5351A, []OutputSpec{
+		{Frequency: 14_097_100 * protocol.Hertz, FineTune: true},
+		{Frequency: 10 * protocol.Megahertz, Integer: true},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	regs := planImage(t, plan)
+	a := ImageFromBytes(regs[:])
+	b := ImageFromBytes(regs[:])
+	b[regMS0+7]++
+	b[regOutputDisable] = 0x02
+	// registers only in one image aren't compared
+	delete(a, 200)
+	b[200] = 1
+
+	changes, outputs, err := DiffImages(25*protocol.Megahertz, a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Address != regOutputDisable || changes[1].Address != regMS0+7 {
+		t.Fatalf("changes %v", changes)
+	}
+	if s, want := changes[1].String(), fmt.Sprintf("49 (MS0+7): 0x%02x -> 0x%02x", regs[regMS0+7], regs[regMS0+7]+1); s != want {
+		t.Errorf("got %q", s)
+	}
+	if len(outputs) != 2 || outputs[0].Old.Output != 0 || outputs[1].Old.Output != 1 {
+		t.Fatalf("outputs %v", outputs)
+	}
+	if outputs[0].Old.Frequency() == outputs[0].New.Frequency() || !outputs[1].Old.Enabled || outputs[1].New.Enabled {
+		t.Errorf("outputs %v", outputs)
+	}
+
+	if changes, outputs, _ := DiffImages(25*protocol.Megahertz, a, a); len(changes) != 0 || len(outputs) != 0 {
+		t.Errorf("image differs from itself: %v %v", changes, outputs)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+/*
+Fixed is an unsigned 64.64 fixed-point number with value Hi + Lo/2^64. It
+holds the ratios that come up in the Si5351 to about 20 significant digits and
+only needs 64 bit integer arithmetic, so it is cheap on a microcontroller
+where math/big is too slow or too big.
+*/
+type Fixed struct {
+	Hi, Lo uint64
+}
+
+// FixedRatio returns a/b rounded down to the nearest 2^-64. The divisor must not be zero.
+func FixedRatio(a, b uint64) Fixed {
+	hi, rem := a/b, a%b
+	lo, _ := bits.Div64(rem, 0, b)
+	return Fixed{Hi: hi, Lo: lo}
+}
+
+// Rat returns the exact value of x
+func (x Fixed) Rat() *big.Rat {
+	n := new(big.Int).SetUint64(x.Hi)
+	n.Lsh(n, 64)
+	n.Or(n, new(big.Int).SetUint64(x.Lo))
+	return new(big.Rat).SetFrac(n, new(big.Int).Lsh(big.NewInt(1), 64))
+}
+
+// Float64 returns x as a float64
+func (x Fixed) Float64() float64 {
+	return float64(x.Hi) + float64(x.Lo)/(1<<64)
+}
+
+/*
+NearestFractionFixed is like NearestFraction but takes a 64.64 fixed-point
+target and works entirely in 64 bit integers. The target must be less than
+2^32 which is far more than any Si5351 ratio.
+
+Returns c/d with d <= max_denominator along with the exact error. The error
+x - c/d is exactly eps/d, negated if negative is true. Since eps is itself a
+fixed-point number this is an exact rational.
+*/
+func NearestFractionFixed(x Fixed, max_denominator uint32) (c, d uint64, eps Fixed, negative bool) {
+	limit := uint64(max_denominator)
+	// h/k are successive convergents, starting with the integer part
+	h0, k0 := uint64(1), uint64(0)
+	h1, k1 := x.Hi, uint64(1)
+	if x.Lo != 0 && x.Lo != 1 {
+		// the remainder is Lo/2^64 so the next term is 2^64/Lo, which
+		// doesn't fit directly in a uint64
+		term, rem := bits.Div64(1, 0, x.Lo)
+		a, b := x.Lo, rem
+		for {
+			if term > (limit-k0)/k1 {
+				break
+			}
+			h0, h1 = h1, term*h1+h0
+			k0, k1 = k1, term*k1+k0
+			if b == 0 {
+				break
+			}
+			term, a, b = a/b, b, a%b
+		}
+	}
+	c, d = h1, k1
+
+	// x*d - c*2^64 is the error times d in fixed point
+	mulHi, mulLo := bits.Mul64(x.Lo, d)
+	mulHi += x.Hi * d
+	if mulHi >= c {
+		lo, borrow := bits.Sub64(mulLo, 0, 0)
+		hi, _ := bits.Sub64(mulHi, c, borrow)
+		return c, d, Fixed{Hi: hi, Lo: lo}, false
+	}
+	lo, borrow := bits.Sub64(0, mulLo, 0)
+	hi, _ := bits.Sub64(c, mulHi, borrow)
+	return c, d, Fixed{Hi: hi, Lo: lo}, true
+}
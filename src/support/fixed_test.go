@@ -0,0 +1,63 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"math/big"
+	"testing"
+)
+
+func Test_fixedRatio(t *testing.T) {
+	x := FixedRatio(7, 4)
+	if x.Hi != 1 || x.Lo != 3<<62 || x.Float64() != 1.75 {
+		t.Errorf("got %+v", x)
+	}
+	if FixedRatio(1, 3).Rat().Cmp(big.NewRat(1, 3)) >= 0 {
+		t.Errorf("1/3 should round down")
+	}
+}
+
+func Test_nearestFractionFixed(t *testing.T) {
+	targets := []Fixed{
+		FixedRatio(144_490_500_146_484_375*6, 25_000_000_000_000_000), // not quite exact
+		FixedRatio(800, 25),
+		FixedRatio(22, 7),
+		{Hi: 34, Lo: 1},
+		{Hi: 0, Lo: 0},
+	}
+	for i := 0; i < 200; i++ {
+		targets = append(targets, Fixed{Hi: uint64(rand() * 2048), Lo: uint64(rand() * (1 << 63) * 2)})
+	}
+	for _, x := range targets {
+		for _, limit := range []uint32{1, 10, 1000, maxDenominator} {
+			c, d, eps, negative := NearestFractionFixed(x, limit)
+			want, _ := NearestRat(x.Rat(), uint64(limit))
+			if want.Cmp(new(big.Rat).SetFrac(new(big.Int).SetUint64(c), new(big.Int).SetUint64(d))) != 0 {
+				t.Errorf("%v limit %d: got %d/%d, want %v", x.Rat(), limit, c, d, want)
+				continue
+			}
+			// c/d + eps/d is exactly x
+			e := new(big.Rat).Quo(eps.Rat(), new(big.Rat).SetUint64(d))
+			if negative {
+				e.Neg(e)
+			}
+			if e.Add(e, want).Cmp(x.Rat()) != 0 {
+				t.Errorf("%v limit %d: error %v is not exact", x.Rat(), limit, eps.Rat())
+			}
+		}
+	}
+}
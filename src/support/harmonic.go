@@ -0,0 +1,88 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"errors"
+	"wspr/src/protocol"
+)
+
+/*
+The Si5351 can't go above 200 MHz, but its outputs are square waves with
+strong odd harmonics. A filter after the output picks out the 3rd or 5th
+harmonic to reach the 1.25m and 70cm bands. Everything about the fundamental
+is multiplied by the harmonic number, including errors, tone spacing and the
+size of the smallest frequency step.
+*/
+
+// maxOutput is the highest frequency the chip generates directly
+const maxOutput = 200 * protocol.Megahertz
+
+/*
+HarmonicPlan is a setting for the fundamental along with the harmonic that is
+actually used. Harmonic is 1 if the target can be generated directly.
+*/
+type HarmonicPlan struct {
+	Harmonic int
+	Config   Si5351Config
+	target   protocol.Frequency
+}
+
+/*
+NewHarmonic plans an output at f from a crystal at f0 using the lowest
+odd harmonic (1, 3 or 5) that brings the fundamental under 200 MHz. The
+fundamental is set up with the Auto strategy. An error is returned if even
+the 5th harmonic would need a fundamental that is too high.
+*/
+func NewHarmonic(f0, f protocol.Frequency) (HarmonicPlan, error) {
+	for _, n := range []int{1, 3, 5} {
+		if f > protocol.Frequency(n)*maxOutput {
+			continue
+		}
+		c, err := NewStrategy(f0, f.MulDiv(1, uint64(n)), Auto)
+		if err != nil {
+			return HarmonicPlan{}, err
+		}
+		return HarmonicPlan{Harmonic: n, Config: c, target: f}, nil
+	}
+	return HarmonicPlan{}, errors.New("HarmonicPlan: frequency is too high even for the 5th harmonic")
+}
+
+// Frequency returns the frequency of the harmonic
+func (h HarmonicPlan) Frequency() protocol.Frequency {
+	return protocol.Frequency(h.Harmonic) * h.Config.Frequency()
+}
+
+// Error returns the requested frequency minus the frequency of the harmonic
+func (h HarmonicPlan) Error() protocol.Frequency {
+	return h.target - h.Frequency()
+}
+
+// Resolution returns the smallest frequency step at the harmonic
+func (h HarmonicPlan) Resolution() protocol.Frequency {
+	return protocol.Frequency(h.Harmonic) * h.Config.Resolution()
+}
+
+/*
+ToneTable computes the register values for sending a schedule on the
+harmonic. The schedule is divided down to the fundamental first so the tone
+spacing comes out right at the harmonic. Every tone uses the same strategy as
+Config.
+*/
+func (h HarmonicPlan) ToneTable(output int, s protocol.Schedule) (ToneTable, error) {
+	return NewToneTableStrategy(h.Config.f0, h.Config.Strategy(), output, s.Divide(h.Harmonic).Points())
+}
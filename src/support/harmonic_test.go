@@ -0,0 +1,84 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"testing"
+	"time"
+	"wspr/src/protocol"
+)
+
+func Test_harmonic(t *testing.T) {
+	for _, test := range []struct {
+		f          protocol.Frequency
+		harmonic   int
+		resolution protocol.Frequency // largest acceptable step
+	}{
+		{144_490_500 * protocol.Hertz, 1, 10 * protocol.MilliHertz},
+		{222_066_500 * protocol.Hertz, 3, 10 * protocol.MilliHertz},
+		{432_301_500 * protocol.Hertz, 3, 10 * protocol.MilliHertz},
+		// a round fundamental needs a PLL fraction with a small denominator
+		{903_500_000 * protocol.Hertz, 5, 500 * protocol.MilliHertz},
+	} {
+		h, err := NewHarmonic(25*protocol.Megahertz, test.f)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.f, err)
+		}
+		if h.Harmonic != test.harmonic || h.Config.f > maxOutput {
+			t.Errorf("%v: harmonic %d of %v", test.f, h.Harmonic, h.Config.f)
+		}
+		if h.Frequency()+h.Error() != test.f || abs(h.Error()) > 50*protocol.MicroHertz {
+			t.Errorf("%v: got %v with error %v", test.f, h.Frequency(), h.Error())
+		}
+		if res := h.Resolution(); res <= 0 || res > test.resolution || res != protocol.Frequency(h.Harmonic)*h.Config.Resolution() {
+			t.Errorf("%v: resolution %v", test.f, res)
+		}
+	}
+
+	if _, err := NewHarmonic(25*protocol.Megahertz, 1296_500*protocol.Kilohertz); err == nil {
+		t.Errorf("expected error for 23cm")
+	}
+}
+
+func Test_harmonicToneTable(t *testing.T) {
+	symbols, _ := protocol.WsprMessage("K1ABC", "FN42", 37)
+	for _, dial := range []protocol.Frequency{144_489_000 * protocol.Hertz, 432_300_000 * protocol.Hertz} {
+		s, err := protocol.NewSchedule(symbols, dial, 1500*protocol.Hertz, protocol.WSPR2, time.Date(2025, 3, 1, 10, 0, 1, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// plan for the first tone so the table should start with the same registers
+		h, err := NewHarmonic(25*protocol.Megahertz, s.Steps[0].Frequency)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		table, err := h.ToneTable(0, s)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if table.Entries[0].Registers != h.Config.registers() {
+			t.Errorf("%v: first entry % x, want % x from %v", dial, table.Entries[0].Registers, h.Config.registers(), h.Config.Strategy())
+		}
+		// tones are spaced correctly at the harmonic
+		for _, e := range table.Entries {
+			step, _ := s.At(table.Start.Add(e.Offset))
+			if abs(protocol.Frequency(h.Harmonic)*e.Frequency-step.Frequency) > protocol.MilliHertz {
+				t.Errorf("entry at %v is %v at the harmonic, want %v", e.Offset, protocol.Frequency(h.Harmonic)*e.Frequency, step.Frequency)
+			}
+		}
+	}
+}
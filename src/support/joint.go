@@ -0,0 +1,143 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"math"
+	"math/big"
+	"wspr/src/protocol"
+)
+
+/*
+New picks the PLL fraction and then the MultiSynth fraction on its own. The
+output depends on the ratio of the two, and there are far more ratios of two
+20 bit fractions than there are 20 bit fractions, so searching over both at
+once can get much closer to the target. For each candidate MultiSynth
+setting the search finds the best PLL fraction for it with BestFraction, so
+the cost is one small continued fraction per candidate.
+*/
+
+// DefaultJointBudget is the number of MultiSynth settings NewJoint tries by default
+const DefaultJointBudget = 20000
+
+// scale for turning the PLL ratio into an integer for BestFraction
+const jointScale = 1 << 50
+
+/*
+NewJoint computes settings for an output at f from a crystal at f0 by
+searching over the PLL and MultiSynth fractions together. MultiSynth settings
+are tried in order of increasing denominator, so nearly integer dividers come
+first, and at most budget of them are tried which bounds the run time. A
+budget of zero means DefaultJointBudget. The result is never worse than what
+New gives.
+
+This is meant for building tables on a host. The second result is the exact
+error, the requested frequency minus the frequency produced, which is often
+smaller than the nano-hertz resolution of a Frequency.
+*/
+func NewJoint(f0, target protocol.Frequency, budget int) (Si5351Config, *big.Rat, error) {
+	if err := checkStrategy(f0, target); err != nil {
+		return Si5351Config{}, nil, err
+	}
+	if budget <= 0 {
+		budget = DefaultJointBudget
+	}
+	x0 := f0.Rat()
+	x := target.Rat()
+
+	best, err := newSplit(f0, target)
+	var bestErr *big.Rat
+	if err == nil {
+		bestErr = exactError(x0, x, best)
+	}
+	if target > maxFractionalOutput {
+		// only even integer dividers are possible up here
+		if c, err := newFinePLL(f0, target); err == nil {
+			if e := exactError(x0, x, c); bestErr == nil || cmpAbs(e, bestErr) < 0 {
+				best, bestErr = c, e
+			}
+		}
+		if bestErr == nil {
+			return Si5351Config{}, nil, err
+		}
+		return best, bestErr, nil
+	}
+
+	f := target.Hz()
+	r := uint32(1)
+	for pllMin.Hz()/(f*float64(r)) > 2048 && r < 128 {
+		r *= 2
+	}
+	lo := math.Max(8, pllMin.Hz()/(f*float64(r)))
+	hi := math.Min(2048, pllMax.Hz()/(f*float64(r)))
+
+	z := new(big.Rat)
+	scaled := new(big.Int)
+	n := 0
+	for c1 := uint64(1); n < budget && c1 <= maxDenominator; c1++ {
+		for a1 := uint64(lo); a1 <= uint64(hi) && n < budget; a1++ {
+			for b1 := uint64(0); b1 < c1 && n < budget; b1++ {
+				if gcd(b1, c1) != 1 {
+					continue
+				}
+				ms := float64(a1) + float64(b1)/float64(c1)
+				if ms < lo || ms > hi {
+					continue
+				}
+				n++
+
+				// the PLL ratio that would hit the target exactly
+				z.SetFrac(new(big.Int).SetUint64((a1*c1+b1)*uint64(r)), new(big.Int).SetUint64(c1))
+				z.Mul(z, x)
+				z.Quo(z, x0)
+				scaled.Mul(z.Num(), big.NewInt(jointScale))
+				scaled.Quo(scaled, z.Denom())
+				num, den, _ := BestFraction(scaled.Uint64(), jointScale, maxDenominator, 0)
+
+				c := makeConfig(f0, target, Split, uint32(num/den), uint32(num%den), uint32(den),
+					uint32(a1), uint32(b1), uint32(c1), r)
+				if c.pll < pllMin || c.pll > pllMax {
+					continue
+				}
+				if e := exactError(x0, x, c); bestErr == nil || cmpAbs(e, bestErr) < 0 {
+					best, bestErr = c, e
+					if e.Sign() == 0 {
+						return best, bestErr, nil
+					}
+				}
+			}
+		}
+	}
+	if bestErr == nil {
+		return Si5351Config{}, nil, err
+	}
+	return best, bestErr, nil
+}
+
+// exactError returns f minus the exact output of a configuration
+func exactError(f0, f *big.Rat, c Si5351Config) *big.Rat {
+	pll := new(big.Rat).SetFrac64(int64(c.a0)*int64(c.c0)+int64(c.b0), int64(c.c0))
+	ms := new(big.Rat).SetFrac64((int64(c.a1)*int64(c.c1)+int64(c.b1))*int64(c.r), int64(c.c1))
+	out := new(big.Rat).Mul(f0, pll)
+	out.Quo(out, ms)
+	return out.Sub(f, out)
+}
+
+// cmpAbs compares |a| and |b|
+func cmpAbs(a, b *big.Rat) int {
+	return new(big.Rat).Abs(a).Cmp(new(big.Rat).Abs(b))
+}
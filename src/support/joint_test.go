@@ -0,0 +1,74 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"math"
+	"testing"
+	"wspr/src/protocol"
+)
+
+func Test_joint(t *testing.T) {
+	for _, test := range []struct {
+		f      string
+		budget int
+		limit  float64 // largest acceptable error (Hz)
+	}{
+		{"7.04010146484375 MHz", 0, 1e-9},
+		{"14.0971029296875 MHz", 0, 1e-9},
+		{"50.294500146484375 MHz", 0, 1e-9},
+		// above 112.5 MHz only the PLL can be fractional
+		{"144.490500146484375 MHz", 0, 1e-4},
+		{"10.00025 kHz", 0, 1e-9},
+		{"14.0971001 MHz", 5, 1e-6},
+	} {
+		f, err := protocol.ParseFrequency(test.f)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.f, err)
+		}
+		c, e, err := NewJoint(25*protocol.Megahertz, f, test.budget)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.f, err)
+		}
+		x0, x := (25 * protocol.Megahertz).Rat(), f.Rat()
+		if e.Cmp(exactError(x0, x, c)) != 0 {
+			t.Errorf("%s: reported error %v doesn't match the settings", test.f, e)
+		}
+		if ef, _ := e.Float64(); math.Abs(ef) > test.limit {
+			t.Errorf("%s: error %g Hz", test.f, ef)
+		}
+		if ef, _ := e.Float64(); abs(c.Error()-protocol.FromHz(ef)) > protocol.NanoHertz {
+			t.Errorf("%s: error %v, exact error %g Hz", test.f, c.Error(), ef)
+		}
+		if plain, err := New(25*protocol.Megahertz, 0, f); err == nil && cmpAbs(e, exactError(x0, x, plain)) > 0 {
+			t.Errorf("%s: worse than New", test.f)
+		}
+		if c.c0 > maxDenominator || c.c1 > maxDenominator || c.b0 >= c.c0 || c.b1 >= c.c1 {
+			t.Errorf("%s: bad dividers %d + %d/%d, %d + %d/%d", test.f, c.a0, c.b0, c.c0, c.a1, c.b1, c.c1)
+		}
+		if c.pll < pllMin || c.pll > pllMax {
+			t.Errorf("%s: PLL %v out of range", test.f, c.pll)
+		}
+		if _, err := c.Registers(PLLA, 0); err != nil {
+			t.Errorf("%s: %v", test.f, err)
+		}
+	}
+
+	if _, _, err := NewJoint(25*protocol.Megahertz, 300*protocol.Megahertz, 0); err == nil {
+		t.Errorf("expected error for 300 MHz")
+	}
+}
@@ -0,0 +1,368 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"wspr/src/protocol"
+)
+
+/*
+This file contains a planner that sets up several Si5351 outputs at once. The
+chip has only two PLLs so outputs have to share them. An output that shares a
+PLL can only be tuned with its own MultiSynth divider which limits how
+accurately it can be set and rules out the low noise even integer mode. The
+planner tries every way of splitting the outputs between the PLLs along with a
+range of PLL frequencies and keeps the plan with the smallest total relative
+error that meets all the constraints.
+*/
+
+const (
+	pllMin = 600 * protocol.Megahertz
+	pllMax = 900 * protocol.Megahertz
+	// largest numerator or denominator that fits in the 20 bit registers
+	maxDenominator = 1<<20 - 1
+	// above this a fractional MultiSynth would have to divide by less than 8
+	maxFractionalOutput = pllMax / 8
+	// outputs above this need the divide by 4 mode
+	minDivBy4Output = 150 * protocol.Megahertz
+)
+
+// Variant identifies the different members of the Si5351 family
+type Variant int
+
+const (
+	// Si5351A is the common 3 output part
+	Si5351A Variant = iota
+	// Si5351A20 is the 20 pin Si5351A with 8 outputs
+	Si5351A20
+	// Si5351C has 8 outputs and an external clock input
+	Si5351C
+)
+
+// Outputs returns the number of clock outputs
+func (v Variant) Outputs() int {
+	if v == Si5351A {
+		return 3
+	}
+	return 8
+}
+
+/*
+OutputSpec describes what is wanted from one output. The output is in
+integer mode if Integer is set, which gives lower phase noise. MaxError is the
+largest acceptable error, or no limit if it is zero. If FineTune is set
+the output gets a PLL that is tuned just for it with an even integer
+MultiSynth divider. At most one output per PLL can be fine tuned.
+*/
+type OutputSpec struct {
+	Frequency protocol.Frequency
+	Integer   bool
+	MaxError  protocol.Frequency
+	FineTune  bool
+}
+
+// OutputPlan is the setting for one output of a FrequencyPlan
+type OutputPlan struct {
+	Output int
+	PLL    PLL
+	Config Si5351Config
+}
+
+// Error returns the requested frequency minus the frequency produced
+func (o OutputPlan) Error() protocol.Frequency {
+	return o.Config.Error()
+}
+
+/*
+FrequencyPlan assigns PLLs and dividers to a set of outputs. PLLFrequency
+is zero for an unused PLL.
+*/
+type FrequencyPlan struct {
+	PLLFrequency [2]protocol.Frequency
+	Outputs      []OutputPlan
+}
+
+/*
+PlanOutputs finds settings for an Si5351 where specs[i] describes output i.
+The crystal frequency is f0. Outputs 6 and 7 only exist on the 8 output parts
+and can only use even integer dividers.
+
+An error is returned if there are more outputs than the chip has or if no
+combination of settings meets the constraints.
+*/
+func PlanOutputs(f0 protocol.Frequency, variant Variant, specs []OutputSpec) (FrequencyPlan, error) {
+	n := len(specs)
+	if n == 0 {
+		return FrequencyPlan{}, errors.New("PlanOutputs: no outputs")
+	}
+	if n > variant.Outputs() {
+		return FrequencyPlan{}, fmt.Errorf("PlanOutputs: %d outputs requested but the chip has %d", n, variant.Outputs())
+	}
+	if f0 < 10*protocol.Megahertz || f0 > 40*protocol.Megahertz {
+		return FrequencyPlan{}, errors.New("PlanOutputs: invalid clock frequency")
+	}
+	for i, s := range specs {
+		if f := s.Frequency; f < pllMin/2048/128 || f > maxOutput {
+			return FrequencyPlan{}, fmt.Errorf("PlanOutputs: output %d frequency %v out of range", i, s.Frequency)
+		}
+	}
+
+	best := FrequencyPlan{}
+	bestScore := math.Inf(1)
+	// the PLLs are interchangeable so output 0 can always be on PLL A
+	for mask := 0; mask < 1<<(n-1); mask++ {
+		var groups [2][]int
+		for i := 0; i < n; i++ {
+			p := (mask << 1 >> i) & 1
+			groups[p] = append(groups[p], i)
+		}
+		candidates := [2][]protocol.Frequency{}
+		ok := true
+		for p, g := range groups {
+			candidates[p], ok = pllCandidates(f0, specs, g)
+			if !ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		for _, pa := range candidates[0] {
+			for _, pb := range candidates[1] {
+				plan, score, ok := evaluatePlan(f0, specs, groups, [2]protocol.Frequency{pa, pb})
+				if ok && score < bestScore {
+					best, bestScore = plan, score
+				}
+			}
+		}
+	}
+	if best.Outputs == nil {
+		return FrequencyPlan{}, errors.New("PlanOutputs: no combination of settings meets the constraints")
+	}
+	return best, nil
+}
+
+/*
+needsInteger is true if an output must use an even integer divider, either
+because it was asked for or because the output can't use a fractional one.
+*/
+func needsInteger(s OutputSpec, output int) bool {
+	return s.Integer || s.FineTune || output >= 6 || s.Frequency > maxFractionalOutput
+}
+
+/*
+pllCandidates lists the PLL frequencies worth trying for a group of outputs.
+If some output needs an integer divider, the PLL has to be a multiple of its
+frequency. Otherwise the PLL is set to an integer multiple of f0 so that it
+runs in integer mode itself. The second result is false if the group is
+impossible.
+*/
+func pllCandidates(f0 protocol.Frequency, specs []OutputSpec, group []int) ([]protocol.Frequency, bool) {
+	if len(group) == 0 {
+		return []protocol.Frequency{0}, true
+	}
+	anchor := -1
+	fine := 0
+	for _, i := range group {
+		if specs[i].FineTune {
+			fine++
+			anchor = i
+		} else if anchor < 0 && needsInteger(specs[i], i) {
+			anchor = i
+		}
+	}
+	if fine > 1 {
+		return nil, false
+	}
+
+	var r []protocol.Frequency
+	if anchor >= 0 {
+		f := specs[anchor].Frequency
+		for _, d := range evenDividers(f, anchor) {
+			r = append(r, f.MulDiv(d, 1))
+		}
+	} else {
+		for m := (pllMin + f0 - 1) / f0; m*f0 <= pllMax; m++ {
+			r = append(r, m*f0)
+		}
+	}
+	return r, len(r) > 0
+}
+
+/*
+evenDividers lists the total division ratios (MultiSynth times R divider) that
+put the PLL for an integer mode output in range.
+*/
+func evenDividers(f protocol.Frequency, output int) []uint64 {
+	var r []uint64
+	if f > minDivBy4Output {
+		if 4*f <= pllMax && output < 6 {
+			r = append(r, 4)
+		}
+		return r
+	}
+	maxMS := uint64(2048)
+	if output >= 6 {
+		maxMS = 254
+	}
+	for rdiv := uint64(1); rdiv <= 128; rdiv *= 2 {
+		for ms := uint64(6); ms <= maxMS; ms += 2 {
+			// MulDiv saturates so a huge product is just out of range
+			if pll := f.MulDiv(ms*rdiv, 1); pll >= pllMin && pll <= pllMax {
+				r = append(r, ms*rdiv)
+			}
+		}
+		if len(r) > 0 {
+			break
+		}
+	}
+	return r
+}
+
+/*
+evaluatePlan works out the settings for every output given the target PLL
+frequencies. The score is the total relative error and the last result is
+false if any constraint isn't met.
+*/
+func evaluatePlan(f0 protocol.Frequency, specs []OutputSpec, groups [2][]int, pll [2]protocol.Frequency) (FrequencyPlan, float64, bool) {
+	plan := FrequencyPlan{Outputs: make([]OutputPlan, len(specs))}
+	score := 0.0
+	for p, g := range groups {
+		if len(g) == 0 {
+			continue
+		}
+		a0, b0, c0 := fraction(ratio(pll[p], f0))
+		for _, i := range g {
+			s := specs[i]
+			integer := needsInteger(s, i)
+			num, den := msRatio(f0, a0, b0, c0, s.Frequency)
+			a1, b1, c1, r, ok := multiSynth(num, den, s.Frequency, integer, i)
+			if !ok {
+				return FrequencyPlan{}, 0, false
+			}
+			// the PLL is an integer multiple of f0 unless an integer output pins it
+			strategy := Split
+			if integer {
+				strategy = FinePLL
+			} else if b0 == 0 {
+				strategy = FineMultiSynth
+			}
+			c := makeConfig(f0, s.Frequency, strategy, a0, b0, c0, a1, b1, c1, r)
+			if s.MaxError > 0 && abs(c.Error()) > s.MaxError {
+				return FrequencyPlan{}, 0, false
+			}
+			plan.PLLFrequency[p] = c.PLLFrequency()
+			score += math.Abs(float64(c.Error())) / float64(s.Frequency)
+			plan.Outputs[i] = OutputPlan{Output: i, PLL: PLL(p), Config: c}
+		}
+	}
+	return plan, score, true
+}
+
+/*
+multiSynth finds the MultiSynth and R divider settings for an output at f
+given the exact ratio num/den of the actual PLL frequency to f. If integer is
+set, the divider is the nearest valid even integer.
+*/
+func multiSynth(num, den *big.Int, f protocol.Frequency, integer bool, output int) (a, b, c, r uint32, ok bool) {
+	if f > minDivBy4Output {
+		// only divide by 4 works up here
+		return 4, 0, 1, 1, output < 6
+	}
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	z := q.Uint64()
+	r = 1
+	for (z > 2048*uint64(r) || z == 2048*uint64(r) && rem.Sign() != 0) && r < 128 {
+		r *= 2
+	}
+	den = new(big.Int).Mul(den, big.NewInt(int64(r)))
+	if integer {
+		// twice the nearest integer to num/den/2
+		q.Add(num, den)
+		q.Quo(q, rem.Lsh(den, 1))
+		even := 2 * q.Int64()
+		maxMS := int64(2048)
+		if output >= 6 {
+			maxMS = 254
+		}
+		if even < 6 || even > maxMS {
+			return 0, 0, 0, 0, false
+		}
+		return uint32(even), 0, 1, r, true
+	}
+	q.QuoRem(num, den, rem)
+	if ms := q.Uint64(); ms < 8 || ms > 2048 || ms == 2048 && rem.Sign() != 0 {
+		return 0, 0, 0, 0, false
+	}
+	a, b, c = fraction(num, den)
+	return a, b, c, r, true
+}
+
+/*
+fraction approximates num/den as a + b/c with c small enough for the
+registers. This is the last convergent of the continued fraction that fits,
+as NearestRat gives, but without building a big.Rat for every divider tried.
+*/
+func fraction(num, den *big.Int) (a, b, c uint32) {
+	x, y := new(big.Int).Set(num), new(big.Int).Set(den)
+	term, rem := new(big.Int), new(big.Int)
+	// h/k are successive convergents, starting with 1/0 and 0/1
+	h0, h1 := uint64(0), uint64(1)
+	k0, k1 := uint64(1), uint64(0)
+	for y.Sign() != 0 {
+		term.QuoRem(x, y, rem)
+		t := term.Uint64()
+		if !term.IsUint64() || k1 != 0 && t > (maxDenominator-k0)/k1 {
+			break
+		}
+		h0, h1 = h1, t*h1+h0
+		k0, k1 = k1, t*k1+k0
+		x, y, rem = y, rem, x
+	}
+	return uint32(h1 / k1), uint32(h1 % k1), uint32(k1)
+}
+
+// ratio returns num/den exactly as a pair of integers
+func ratio(num, den protocol.Frequency) (*big.Int, *big.Int) {
+	return big.NewInt(int64(num)), big.NewInt(int64(den))
+}
+
+// msRatio returns the exact ratio of the PLL set to a0 + b0/c0 times f0 to an output at f
+func msRatio(f0 protocol.Frequency, a0, b0, c0 uint32, f protocol.Frequency) (*big.Int, *big.Int) {
+	num := big.NewInt(int64(f0))
+	num.Mul(num, big.NewInt(int64(a0)*int64(c0)+int64(b0)))
+	den := big.NewInt(int64(f))
+	return num, den.Mul(den, big.NewInt(int64(c0)))
+}
+
+// makeConfig builds a configuration from divider settings and works out what it produces
+func makeConfig(f0, target protocol.Frequency, strategy Strategy, a0, b0, c0, a1, b1, c1, r uint32) Si5351Config {
+	c := Si5351Config{f0: f0, a0: a0, b0: b0, c0: c0, a1: a1, b1: b1, c1: c1, r: r, target: target, strategy: strategy}
+	c.settle()
+	return c
+}
+
+func abs(f protocol.Frequency) protocol.Frequency {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"testing"
+	"wspr/src/protocol"
+)
+
+func Test_planOutputs(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		variant Variant
+		specs   []OutputSpec
+	}{
+		{
+			name:    "single",
+			variant: Si5351A,
+			specs:   []OutputSpec{{Frequency: 14_097_100 * protocol.Hertz, FineTune: true, MaxError: protocol.MilliHertz}},
+		},
+		{
+			// WSPR on 2m with a fractional receiver clock and a GPS reference
+			name:    "three",
+			variant: Si5351A,
+			specs: []OutputSpec{
+				{Frequency: 144_490_500 * protocol.Hertz, FineTune: true, MaxError: protocol.MilliHertz},
+				{Frequency: 10e6 * protocol.Hertz, Integer: true, MaxError: protocol.MicroHertz},
+				{Frequency: 27_000_001 * protocol.Hertz, MaxError: protocol.Hertz},
+			},
+		},
+		{
+			name:    "eight",
+			variant: Si5351C,
+			specs: []OutputSpec{
+				{Frequency: 7_040_100 * protocol.Hertz, FineTune: true, MaxError: protocol.MilliHertz},
+				{Frequency: 10e6 * protocol.Hertz, Integer: true},
+				{Frequency: 12_345_678 * protocol.Hertz, MaxError: protocol.Hertz},
+				{Frequency: 3.3e6 * protocol.Hertz, MaxError: protocol.Hertz},
+				{Frequency: 48e6 * protocol.Hertz},
+				{Frequency: 100e3 * protocol.Hertz, MaxError: protocol.Hertz},
+				{Frequency: 20e6 * protocol.Hertz},
+				{Frequency: 5e6 * protocol.Hertz},
+			},
+		},
+		{
+			name:    "divby4",
+			variant: Si5351A,
+			specs:   []OutputSpec{{Frequency: 180e6 * protocol.Hertz}, {Frequency: 30e6 * protocol.Hertz, Integer: true}},
+		},
+	} {
+		plan, err := PlanOutputs(25*protocol.Megahertz, test.variant, test.specs)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(plan.Outputs) != len(test.specs) {
+			t.Fatalf("%s: got %d outputs", test.name, len(plan.Outputs))
+		}
+		fine := map[PLL]int{}
+		for i, out := range plan.Outputs {
+			s := test.specs[i]
+			c := out.Config
+			if out.Output != i || c.PLLFrequency() != plan.PLLFrequency[out.PLL] {
+				t.Errorf("%s: output %d is on %v at %v", test.name, i, out.PLL, c.PLLFrequency())
+			}
+			if c.pll < pllMin || c.pll > pllMax {
+				t.Errorf("%s: output %d PLL %v out of range", test.name, i, c.pll)
+			}
+			if s.MaxError > 0 && abs(out.Error()) > s.MaxError {
+				t.Errorf("%s: output %d error %v", test.name, i, out.Error())
+			}
+			if c.Frequency()+out.Error() != s.Frequency {
+				t.Errorf("%s: output %d inconsistent error", test.name, i)
+			}
+			if needsInteger(s, i) && (c.b1 != 0 || c.a1%2 != 0) {
+				t.Errorf("%s: output %d divider %d + %d/%d not even", test.name, i, c.a1, c.b1, c.c1)
+			}
+			if s.FineTune {
+				fine[out.PLL]++
+			}
+			if _, err := c.Registers(out.PLL, i); err != nil {
+				t.Errorf("%s: output %d: %v", test.name, i, err)
+			}
+		}
+		for p, n := range fine {
+			if n > 1 {
+				t.Errorf("%s: %d fine tuned outputs on %v", test.name, n, p)
+			}
+		}
+	}
+}
+
+func Test_planOutputsShared(t *testing.T) {
+	// 10 and 20 MHz can share an integer PLL so the fine tuned output gets the other
+	plan, err := PlanOutputs(25*protocol.Megahertz, Si5351A, []OutputSpec{
+		{Frequency: 10e6 * protocol.Hertz, Integer: true, MaxError: protocol.NanoHertz},
+		{Frequency: 14_097_100 * protocol.Hertz, FineTune: true},
+		{Frequency: 20e6 * protocol.Hertz, Integer: true, MaxError: protocol.NanoHertz},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan.Outputs[0].PLL != plan.Outputs[2].PLL || plan.Outputs[1].PLL == plan.Outputs[0].PLL {
+		t.Errorf("PLL assignment %v %v %v", plan.Outputs[0].PLL, plan.Outputs[1].PLL, plan.Outputs[2].PLL)
+	}
+}
+
+func Test_planOutputsErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		variant Variant
+		specs   []OutputSpec
+	}{
+		{"none", Si5351A, nil},
+		{"too many", Si5351A, make([]OutputSpec, 4)},
+		{"range", Si5351A, []OutputSpec{{Frequency: 250e6 * protocol.Hertz}}},
+		{
+			// three fine tuned outputs need three PLLs
+			"fine", Si5351A,
+			[]OutputSpec{{Frequency: 7e6 * protocol.Hertz, FineTune: true}, {Frequency: 10e6 * protocol.Hertz, FineTune: true}, {Frequency: 14e6 * protocol.Hertz, FineTune: true}},
+		},
+		{
+			// unrelated integer outputs can't share a PLL exactly
+			"integer", Si5351A,
+			[]OutputSpec{
+				{Frequency: 7_040_100 * protocol.Hertz, Integer: true, MaxError: protocol.MilliHertz},
+				{Frequency: 10_138_700 * protocol.Hertz, Integer: true, MaxError: protocol.MilliHertz},
+				{Frequency: 14_097_100 * protocol.Hertz, Integer: true, MaxError: protocol.MilliHertz},
+			},
+		},
+		{
+			// outputs 6 and 7 can't divide by 4
+			"output 7", Si5351C,
+			[]OutputSpec{{Frequency: 1e6 * protocol.Hertz}, {}, {}, {}, {}, {}, {}, {Frequency: 180e6 * protocol.Hertz}},
+		},
+	} {
+		for i := range test.specs {
+			if test.specs[i].Frequency == 0 {
+				test.specs[i].Frequency = 10 * protocol.Megahertz
+			}
+		}
+		if _, err := PlanOutputs(25*protocol.Megahertz, test.variant, test.specs); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"errors"
+	"math"
+	"wspr/src/protocol"
+)
+
+/*
+A crystal is never exactly at its nominal frequency. A typical 25 MHz crystal
+is off by a few ppm, which is tens of Hz at 2m and a good fraction of the WSPR
+band. The frequency counter measures the real crystal frequency against GPS PPS
+and a Reference carries that measurement into the synthesis code so that the
+dividers are computed from the true frequency instead of the nominal one.
+*/
+
+// maxOffset is the largest believable crystal error in ppb
+const maxOffset = 1e6
+
+/*
+Reference is a clock reference with a nominal frequency and the frequency it
+actually runs at. A Reference with the two equal is the same as assuming that
+the crystal is exact.
+*/
+type Reference struct {
+	Nominal protocol.Frequency
+	Actual  protocol.Frequency
+}
+
+// PPBReference returns a reference that is off from nominal by ppb parts per billion
+func PPBReference(nominal protocol.Frequency, ppb float64) (Reference, error) {
+	return MeasuredReference(nominal, nominal+protocol.FromHz(nominal.Hz()*ppb*1e-9))
+}
+
+// MeasuredReference returns a reference that actually runs at measured
+func MeasuredReference(nominal, measured protocol.Frequency) (Reference, error) {
+	if nominal <= 0 || measured <= 0 {
+		return Reference{}, errors.New("Reference: frequencies must be positive")
+	}
+	r := Reference{Nominal: nominal, Actual: measured}
+	if math.Abs(r.PPB()) > maxOffset {
+		return Reference{}, errors.New("Reference: measured frequency is too far from nominal")
+	}
+	return r, nil
+}
+
+/*
+CountedReference returns a reference from counting reference cycles over a
+number of seconds marked by PPS pulses. Counting over more seconds gives a
+more precise result since each second is good to about one count.
+*/
+func CountedReference(nominal protocol.Frequency, counts uint64, seconds int) (Reference, error) {
+	if seconds <= 0 {
+		return Reference{}, errors.New("Reference: need at least one second of counts")
+	}
+	return MeasuredReference(nominal, protocol.Hertz.MulDiv(counts, uint64(seconds)))
+}
+
+// PPB returns the offset of the reference from nominal in parts per billion
+func (r Reference) PPB() float64 {
+	return float64(r.Actual-r.Nominal) / float64(r.Nominal) * 1e9
+}
+
+// Frequency returns the actual frequency of the reference
+func (r Reference) Frequency() protocol.Frequency {
+	return r.Actual
+}
+
+/*
+Offset returns how far off an output at f would be if the settings were
+computed assuming the nominal frequency. This is the error that calibration
+removes. A zero Reference has no offset.
+*/
+func (r Reference) Offset(f protocol.Frequency) protocol.Frequency {
+	if f < 0 {
+		return -r.Offset(-f)
+	}
+	if r.Nominal <= 0 {
+		return 0
+	}
+	return (r.Actual - r.Nominal).MulDiv(uint64(f), uint64(r.Nominal))
+}
+
+/*
+Config computes Si5351 settings like New does, but based on the actual
+frequency of the reference. The residual error left after calibration is
+given by the Error method of the result.
+*/
+func (r Reference) Config(pll, f protocol.Frequency) (Si5351Config, error) {
+	return New(r.Actual, pll, f)
+}
+
+// PlanOutputs plans several outputs like PlanOutputs does, but using the actual reference frequency
+func (r Reference) PlanOutputs(variant Variant, specs []OutputSpec) (FrequencyPlan, error) {
+	return PlanOutputs(r.Actual, variant, specs)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"math"
+	"testing"
+	"wspr/src/protocol"
+)
+
+func Test_reference(t *testing.T) {
+	// a 25 MHz crystal that is 10 ppm fast, counted over 10 seconds
+	ref, err := CountedReference(25*protocol.Megahertz, 250_002_500, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(ref.PPB()-10_000) > 1e-6 || ref.Frequency() != 25_000_250*protocol.Hertz {
+		t.Errorf("got %.3f ppb, %v", ref.PPB(), ref.Frequency())
+	}
+	for _, other := range []Reference{
+		{Nominal: 25 * protocol.Megahertz, Actual: 25_000_250 * protocol.Hertz},
+		mustReference(PPBReference(25*protocol.Megahertz, 10_000)),
+		mustReference(MeasuredReference(25*protocol.Megahertz, 25_000_250*protocol.Hertz)),
+	} {
+		if other != ref {
+			t.Errorf("got %+v, want %+v", other, ref)
+		}
+	}
+	// a third of a count per second is kept exactly
+	if r, _ := CountedReference(25*protocol.Megahertz, 75_000_001, 3); r.Actual != 25*protocol.Megahertz+333_333_333 {
+		t.Errorf("counted %v", r.Actual)
+	}
+	// offsets are odd in f and a zero reference has none
+	if off := ref.Offset(-10 * protocol.Megahertz); off != -100*protocol.Hertz || ref.Offset(10*protocol.Megahertz) != -off {
+		t.Errorf("offset at -10 MHz is %v", off)
+	}
+	if off := (Reference{}).Offset(10 * protocol.Megahertz); off != 0 {
+		t.Errorf("zero reference has offset %v", off)
+	}
+
+	for _, f := range []protocol.Frequency{7_040_100 * protocol.Hertz, 14_097_100 * protocol.Hertz, 50_294_500 * protocol.Hertz, 144_490_500 * protocol.Hertz} {
+		// the nominal settings really produce a frequency scaled by the crystal error
+		nominal, err := New(25*protocol.Megahertz, 0, f)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		off := nominal.Frequency().MulDiv(uint64(ref.Actual), uint64(ref.Nominal)) - f
+		if abs(off-ref.Offset(f)) > protocol.MilliHertz {
+			t.Errorf("%v: uncalibrated error %v, expected %v", f, off, ref.Offset(f))
+		}
+
+		calibrated, err := ref.Config(0, f)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calibrated.f0 != ref.Frequency() || abs(calibrated.Error()) > 20*protocol.MicroHertz {
+			t.Errorf("%v: calibrated residual %v", f, calibrated.Error())
+		}
+	}
+
+	plan, err := ref.PlanOutputs(Si5351A, []OutputSpec{
+		{Frequency: 144_490_500 * protocol.Hertz, FineTune: true, MaxError: protocol.MilliHertz},
+		{Frequency: 10 * protocol.Megahertz, MaxError: protocol.MilliHertz},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, out := range plan.Outputs {
+		if out.Config.f0 != ref.Frequency() {
+			t.Errorf("output %d planned from %v", out.Output, out.Config.f0)
+		}
+	}
+}
+
+func Test_referenceHigh(t *testing.T) {
+	// a 27 MHz crystal running 1 ppm high is just over the nominal value
+	ref, err := PPBReference(27*protocol.Megahertz, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, f := range []protocol.Frequency{7_040_100 * protocol.Hertz, 14_097_100 * protocol.Hertz} {
+		c, err := ref.Config(0, f)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", f, err)
+		}
+		if c.f0 != 27_000_027*protocol.Hertz || abs(c.Error()) > 20*protocol.MicroHertz {
+			t.Errorf("%v: from %v with error %v", f, c.f0, c.Error())
+		}
+	}
+}
+
+func mustReference(r Reference, err error) Reference {
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func Test_referenceErrors(t *testing.T) {
+	if _, err := CountedReference(25*protocol.Megahertz, 25_000_000, 0); err == nil {
+		t.Errorf("expected error for no counts")
+	}
+	if _, err := MeasuredReference(25*protocol.Megahertz, 26*protocol.Megahertz); err == nil {
+		t.Errorf("expected error for a 4%% offset")
+	}
+	if _, err := MeasuredReference(0, 25*protocol.Megahertz); err == nil {
+		t.Errorf("expected error for zero nominal")
+	}
+}
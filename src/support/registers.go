@@ -0,0 +1,219 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// register addresses from Silicon Labs AN619
+	regClk0Control = 16
+	regPLLAInt     = 22
+	regPLLBInt     = 23
+	regPLLA        = 26
+	regPLLB        = 34
+	regMS0         = 42
+	regMS6         = 90
+	regMS7         = 91
+	regR67         = 92
+
+	// bits in the CLKn control registers
+	clkPowerDown = 0x80
+	clkMSInt     = 0x40
+	clkMSSrcPLLB = 0x20
+	clkInvert    = 0x10
+	clkSrcMS     = 0x0c
+	clkDrive8mA  = 0x03
+
+	// bit in registers 22 and 23 for an integer PLL feedback divider
+	pllFBInt = 0x40
+	// bits in the MultiSynth block for divide by 4
+	msDivBy4 = 0x0c
+)
+
+// PLL selects one of the two PLLs in the Si5351
+type PLL int
+
+const (
+	PLLA PLL = iota
+	PLLB
+)
+
+func (p PLL) String() string {
+	if p == PLLB {
+		return "PLLB"
+	}
+	return "PLLA"
+}
+
+/*
+Register is the value of a single Si5351 register. Only the bits set in Mask
+are given by Value. The other bits belong to some other output and should be
+left as they are.
+*/
+type Register struct {
+	Address uint8
+	Value   byte
+	Mask    byte
+}
+
+/*
+Registers returns the register values that set up the given PLL and output
+for this configuration, in address order. These are the PLL parameters
+(registers 26-33 or 34-41), the MultiSynth parameters (8 registers from
+42+8*output for outputs 0-5, or register 90 or 91 for outputs 6 and 7), the
+CLKn control register (16+output) and the PLL integer mode bit (bit 6 of
+register 22 or 23).
+
+The MultiSynth is put into integer mode when the divider is an even integer
+and an output divider of exactly 4 uses the DIVBY4 setting. The PLL is put
+into integer mode when its feedback divider is an integer. The control
+register powers the output up with 8 mA drive, no inversion and the
+MultiSynth as its source.
+
+Some registers are shared. Registers 22 and 23 are the CLK6 and CLK7 control
+registers, but bit 6 in each holds the integer mode flag for PLL A or B. The
+R dividers for outputs 6 and 7 share register 92. Mask shows which bits of
+these registers belong to this configuration.
+
+An error is returned if the output or PLL doesn't exist or if outputs 6 and 7
+are asked for anything other than an even integer divider.
+*/
+func (c Si5351Config) Registers(pll PLL, output int) ([]Register, error) {
+	if pll != PLLA && pll != PLLB {
+		return nil, fmt.Errorf("Si5351Config: invalid PLL %d", pll)
+	}
+	if output < 0 || output > 7 {
+		return nil, fmt.Errorf("Si5351Config: invalid output %d", output)
+	}
+
+	regs := map[uint8]Register{}
+	add := func(address int, value, mask byte) {
+		r := regs[uint8(address)]
+		regs[uint8(address)] = Register{uint8(address), r.Value | value&mask, r.Mask | mask}
+	}
+	addBlock := func(base int, block [8]byte) {
+		for i, v := range block {
+			add(base+i, v, 0xff)
+		}
+	}
+
+	addBlock(regPLLA+8*int(pll), c.pllBlock())
+
+	control := byte(clkSrcMS | clkDrive8mA)
+	if pll == PLLB {
+		control |= clkMSSrcPLLB
+	}
+	if output < 6 {
+		if c.msInteger() {
+			control |= clkMSInt
+		}
+		add(regClk0Control+output, control, 0xff)
+		addBlock(regMS0+8*output, c.msBlock())
+	} else {
+		if !c.msInteger() || c.a1 < 6 || c.a1 > 254 {
+			return nil, fmt.Errorf("Si5351Config: output %d needs an even integer divider from 6 to 254, not %d+%d/%d",
+				output, c.a1, c.b1, c.c1)
+		}
+		// bit 6 is the PLL integer flag, not part of the output control
+		add(regClk0Control+output, control, ^byte(pllFBInt))
+		add(regMS6+output-6, byte(c.a1), 0xff)
+		shift := 4 * (output - 6)
+		add(regR67, log2(c.r)<<shift, 0x07<<shift)
+	}
+
+	fb := byte(0)
+	if c.b0 == 0 {
+		fb = pllFBInt
+	}
+	add(regPLLAInt+int(pll), fb, pllFBInt)
+
+	out := make([]Register, 0, len(regs))
+	for _, r := range regs {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out, nil
+}
+
+// pllBlock returns the 8 PLL feedback divider registers
+func (c Si5351Config) pllBlock() [8]byte {
+	p1, p2, p3 := dividerParams(c.a0, c.b0, c.c0)
+	return dividerRegisters(p1, p2, p3, 0)
+}
+
+// msBlock returns the 8 MultiSynth registers including the R divider
+func (c Si5351Config) msBlock() [8]byte {
+	if c.divBy4() {
+		block := dividerRegisters(0, 0, 1, log2(c.r))
+		block[2] |= msDivBy4
+		return block
+	}
+	p1, p2, p3 := dividerParams(c.a1, c.b1, c.c1)
+	return dividerRegisters(p1, p2, p3, log2(c.r))
+}
+
+// msInteger is true if the MultiSynth can run in integer mode
+func (c Si5351Config) msInteger() bool {
+	return c.b1 == 0 && c.a1%2 == 0
+}
+
+// divBy4 is true if the MultiSynth divides by exactly 4
+func (c Si5351Config) divBy4() bool {
+	return c.a1 == 4 && c.b1 == 0
+}
+
+/*
+dividerParams converts a divider ratio a + b/c into the P1, P2 and P3 values
+that are written to the Si5351 as described in Silicon Labs AN619.
+*/
+func dividerParams(a, b, c uint32) (p1, p2, p3 uint32) {
+	f := 128 * uint64(b) / uint64(c)
+	p1 = uint32(128*uint64(a) + f - 512)
+	p2 = uint32(128*uint64(b) - uint64(c)*f)
+	return p1, p2, c
+}
+
+/*
+dividerRegisters packs P1, P2 and P3 into the 8 register layout shared by the
+PLLs and MultiSynths 0 to 5. The R divider is given as log2 of the division
+ratio and only applies to MultiSynths.
+*/
+func dividerRegisters(p1, p2, p3 uint32, rdiv uint8) [8]byte {
+	return [8]byte{
+		byte(p3 >> 8),
+		byte(p3),
+		rdiv<<4 | byte(p1>>16)&0x03,
+		byte(p1 >> 8),
+		byte(p1),
+		byte(p3>>16)<<4 | byte(p2>>16)&0x0f,
+		byte(p2 >> 8),
+		byte(p2),
+	}
+}
+
+// log2 returns the R divider code for a power of two division ratio
+func log2(r uint32) uint8 {
+	n := uint8(0)
+	for r > 1 {
+		r >>= 1
+		n++
+	}
+	return n
+}
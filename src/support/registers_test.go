@@ -0,0 +1,153 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"testing"
+	"wspr/src/protocol"
+)
+
+func Test_dividerRegisters(t *testing.T) {
+	// 34 + 16943/25000 from the 2m example in the README
+	p1, p2, p3 := dividerParams(34, 16943, 25000)
+	if p1 != 3926 || p2 != 18704 || p3 != 25000 {
+		t.Errorf("got P1=%d, P2=%d, P3=%d", p1, p2, p3)
+	}
+	got := dividerRegisters(p1, p2, p3, 2)
+	want := [8]byte{0x61, 0xa8, 0x20, 0x0f, 0x56, 0x00, 0x49, 0x10}
+	if got != want {
+		t.Errorf("got % x, want % x", got, want)
+	}
+
+	// 20 bit values spill into the shared register
+	got = dividerRegisters(0x3ffff, 0xabcde, 0xfffff, 0)
+	want = [8]byte{0xff, 0xff, 0x03, 0xff, 0xff, 0xfa, 0xbc, 0xde}
+	if got != want {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+func Test_registers(t *testing.T) {
+	pll10 := []byte{0x00, 0x01, 0x00, 0x0e, 0x00, 0x00, 0x00, 0x00} // 32
+	for _, test := range []struct {
+		name   string
+		f      protocol.Frequency
+		pll    PLL
+		output int
+		blocks map[uint8][]byte // runs of registers with a full mask
+		masked map[uint8][2]byte
+	}{
+		{
+			// 800 MHz PLL = 32 * 25 MHz and 80x MultiSynth are both integers
+			name: "integer", f: 10 * protocol.Megahertz, pll: PLLA, output: 0,
+			blocks: map[uint8][]byte{
+				16: {0x4f},
+				26: pll10,
+				42: {0x00, 0x01, 0x00, 0x26, 0x00, 0x00, 0x00, 0x00},
+			},
+			masked: map[uint8][2]byte{22: {0x40, 0x40}},
+		},
+		{
+			// 720 MHz PLL = 28.8 * 25 MHz and MultiSynth divides by 4
+			name: "divby4", f: 180 * protocol.Megahertz, pll: PLLB, output: 2,
+			blocks: map[uint8][]byte{
+				18: {0x6f},
+				34: {0x00, 0x05, 0x00, 0x0c, 0x66, 0x00, 0x00, 0x02},
+				58: {0x00, 0x01, 0x0c, 0x00, 0x00, 0x00, 0x00, 0x00},
+			},
+			masked: map[uint8][2]byte{23: {0x00, 0x40}},
+		},
+		{
+			// 600 MHz / 1875 / 32, odd divider so no integer mode
+			name: "r divider", f: 10 * protocol.Kilohertz, pll: PLLA, output: 5,
+			blocks: map[uint8][]byte{
+				21: {0x0f},
+				26: {0x00, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x00},
+				82: {0x00, 0x01, 0x53, 0xa7, 0x80, 0x00, 0x00, 0x00},
+			},
+			masked: map[uint8][2]byte{22: {0x40, 0x40}},
+		},
+		{
+			// the CLK7 control register also holds the PLL B integer flag
+			name: "output 7", f: 10 * protocol.Megahertz, pll: PLLB, output: 7,
+			blocks: map[uint8][]byte{
+				23: {0x6f},
+				34: pll10,
+				91: {80},
+			},
+			masked: map[uint8][2]byte{92: {0x00, 0x70}},
+		},
+		{
+			name: "output 6", f: 10 * protocol.Megahertz, pll: PLLB, output: 6,
+			blocks: map[uint8][]byte{
+				34: pll10,
+				90: {80},
+			},
+			masked: map[uint8][2]byte{22: {0x2f, 0xbf}, 23: {0x40, 0x40}, 92: {0x00, 0x07}},
+		},
+	} {
+		config, err := New(25*protocol.Megahertz, 0, test.f)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if config.f != test.f {
+			t.Errorf("%s: frequency %v", test.name, config.f)
+		}
+		regs, err := config.Registers(test.pll, test.output)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		want := map[uint8]Register{}
+		for base, block := range test.blocks {
+			for i, v := range block {
+				want[base+uint8(i)] = Register{base + uint8(i), v, 0xff}
+			}
+		}
+		for a, vm := range test.masked {
+			want[a] = Register{a, vm[0], vm[1]}
+		}
+		if len(regs) != len(want) {
+			t.Errorf("%s: got %d registers, want %d", test.name, len(regs), len(want))
+		}
+		for i, r := range regs {
+			if i > 0 && r.Address <= regs[i-1].Address {
+				t.Errorf("%s: register %d out of order", test.name, r.Address)
+			}
+			if r != want[r.Address] {
+				t.Errorf("%s: got %+v, want %+v", test.name, r, want[r.Address])
+			}
+		}
+	}
+}
+
+func Test_registersErrors(t *testing.T) {
+	odd, _ := New(25*protocol.Megahertz, 0, 10*protocol.Kilohertz)
+	for _, test := range []struct {
+		config Si5351Config
+		pll    PLL
+		output int
+	}{
+		{odd, PLLA, 6},
+		{odd, PLLA, 8},
+		{odd, PLLA, -1},
+		{odd, PLL(2), 0},
+	} {
+		if _, err := test.config.Registers(test.pll, test.output); err == nil {
+			t.Errorf("expected error for %v output %d", test.pll, test.output)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"errors"
+	"wspr/src/protocol"
+)
+
+/*
+Strategy says where the fine tuning of an output happens. Fractional dividers
+add phase noise and above about 50 MHz it is better to fine tune the PLL and
+leave the output MultiSynth at an even integer. Below that it is usually
+better to run the PLL as an integer multiple of the crystal and fine tune the
+MultiSynth, which also leaves the PLL free to be shared with other outputs.
+*/
+type Strategy int
+
+const (
+	// Split lets both the PLL and the MultiSynth be fractional, as New does
+	Split Strategy = iota
+	// FinePLL tunes the PLL and uses an even integer MultiSynth
+	FinePLL
+	// FineMultiSynth uses an integer PLL and tunes the MultiSynth
+	FineMultiSynth
+	// Auto picks one of the others based on the frequency
+	Auto
+)
+
+// finePLLThreshold is where Auto switches to tuning the PLL
+const finePLLThreshold = 50 * protocol.Megahertz
+
+func (s Strategy) String() string {
+	switch s {
+	case Split:
+		return "split"
+	case FinePLL:
+		return "fine PLL"
+	case FineMultiSynth:
+		return "fine MultiSynth"
+	case Auto:
+		return "auto"
+	default:
+		return "unknown"
+	}
+}
+
+/*
+Strategy returns the strategy that was used to compute a configuration. A
+divider that happens to come out as an integer doesn't change this, so a
+FineMultiSynth setting is still reported as such when its MultiSynth is even.
+*/
+func (c Si5351Config) Strategy() Strategy {
+	return c.strategy
+}
+
+/*
+guessStrategy works out a strategy from which dividers are fractional. This
+is only needed for settings read back from the chip where the strategy that
+was used isn't known.
+*/
+func (c Si5351Config) guessStrategy() Strategy {
+	if c.b1 == 0 && c.a1%2 == 0 {
+		return FinePLL
+	}
+	if c.b0 == 0 {
+		return FineMultiSynth
+	}
+	return Split
+}
+
+/*
+NewStrategy computes settings for an output at f from a crystal at f0
+using a particular strategy. With Auto, outputs at 50 MHz and up use FinePLL
+and lower outputs use whichever of FineMultiSynth or Split is more accurate,
+preferring FineMultiSynth. The strategy actually used is reported by the
+Strategy method of the result.
+*/
+func NewStrategy(f0, f protocol.Frequency, strategy Strategy) (Si5351Config, error) {
+	switch strategy {
+	case Split:
+		return newSplit(f0, f)
+	case FinePLL:
+		return newFinePLL(f0, f)
+	case FineMultiSynth:
+		return newFineMultiSynth(f0, f)
+	case Auto:
+		if f >= finePLLThreshold {
+			return newFinePLL(f0, f)
+		}
+		fine, err := newFineMultiSynth(f0, f)
+		split, err2 := newSplit(f0, f)
+		if err2 != nil || (err == nil && abs(fine.Error()) <= abs(split.Error())) {
+			return fine, err
+		}
+		return split, nil
+	default:
+		return Si5351Config{}, errors.New("Si5351Config: unknown strategy")
+	}
+}
+
+// newSplit lets New pick both dividers
+func newSplit(f0, f protocol.Frequency) (Si5351Config, error) {
+	return New(f0, 0, f)
+}
+
+// newFinePLL finds the even integer divider that gives the most accurate PLL setting
+func newFinePLL(f0, target protocol.Frequency) (Si5351Config, error) {
+	if err := checkStrategy(f0, target); err != nil {
+		return Si5351Config{}, err
+	}
+	best := Si5351Config{}
+	for _, d := range evenDividers(target, 0) {
+		a0, b0, c0 := fraction(ratio(target.MulDiv(d, 1), f0))
+		num, den := msRatio(f0, a0, b0, c0, target)
+		a1, b1, c1, r, ok := multiSynth(num, den, target, true, 0)
+		if !ok {
+			continue
+		}
+		c := makeConfig(f0, target, FinePLL, a0, b0, c0, a1, b1, c1, r)
+		if best.f == 0 || abs(c.Error()) < abs(best.Error()) {
+			best = c
+		}
+	}
+	if best.f == 0 {
+		return Si5351Config{}, errors.New("Si5351Config: no even integer divider for this frequency")
+	}
+	return best, nil
+}
+
+// newFineMultiSynth finds the integer PLL multiplier that gives the most accurate output
+func newFineMultiSynth(f0, target protocol.Frequency) (Si5351Config, error) {
+	if err := checkStrategy(f0, target); err != nil {
+		return Si5351Config{}, err
+	}
+	if target > maxFractionalOutput {
+		return Si5351Config{}, errors.New("Si5351Config: output too high for a fractional MultiSynth")
+	}
+	best := Si5351Config{}
+	for m := (pllMin + f0 - 1) / f0; m*f0 <= pllMax; m++ {
+		num, den := ratio(m*f0, target)
+		a1, b1, c1, r, ok := multiSynth(num, den, target, false, 0)
+		if !ok {
+			continue
+		}
+		c := makeConfig(f0, target, FineMultiSynth, uint32(m), 0, 1, a1, b1, c1, r)
+		if best.f == 0 || abs(c.Error()) < abs(best.Error()) {
+			best = c
+		}
+	}
+	if best.f == 0 {
+		return Si5351Config{}, errors.New("Si5351Config: no integer PLL setting for this frequency")
+	}
+	return best, nil
+}
+
+func checkStrategy(f0, f protocol.Frequency) error {
+	if f0 < 10*protocol.Megahertz || f0 > 40*protocol.Megahertz {
+		return errors.New("Si5351Config: invalid clock frequency")
+	}
+	if f < pllMin/2048/128 || f > maxOutput {
+		return errors.New("Si5351Config: output frequency out of range")
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"testing"
+	"wspr/src/protocol"
+)
+
+func Test_strategy(t *testing.T) {
+	for _, test := range []struct {
+		f        protocol.Frequency
+		strategy Strategy
+		want     Strategy
+	}{
+		{14_097_100 * protocol.Hertz, Auto, FineMultiSynth},
+		{50_294_500 * protocol.Hertz, Auto, FinePLL},
+		{70_091_000 * protocol.Hertz, Auto, FinePLL},
+		{144_490_500 * protocol.Hertz, Auto, FinePLL},
+		{180e6 * protocol.Hertz, Auto, FinePLL},
+		{7_040_100 * protocol.Hertz, FinePLL, FinePLL},
+		{50_294_500 * protocol.Hertz, FineMultiSynth, FineMultiSynth},
+		{10_140_200 * protocol.Hertz, FineMultiSynth, FineMultiSynth},
+		{3_570_100 * protocol.Hertz, FinePLL, FinePLL},
+	} {
+		c, err := NewStrategy(25*protocol.Megahertz, test.f, test.strategy)
+		if err != nil {
+			t.Fatalf("%v %v: unexpected error: %v", test.f, test.strategy, err)
+		}
+		if c.Strategy() != test.want {
+			t.Errorf("%v %v: got %v (%d + %d/%d, %d + %d/%d)", test.f, test.strategy, c.Strategy(),
+				c.a0, c.b0, c.c0, c.a1, c.b1, c.c1)
+		}
+		if abs(c.Error()) > test.f.MulDiv(1, 1e10) {
+			t.Errorf("%v %v: error %v", test.f, test.strategy, c.Error())
+		}
+		if c.pll < pllMin || c.pll > pllMax {
+			t.Errorf("%v %v: PLL %v out of range", test.f, test.strategy, c.pll)
+		}
+		if _, err := c.Registers(PLLA, 0); err != nil {
+			t.Errorf("%v %v: %v", test.f, test.strategy, err)
+		}
+	}
+
+	// dividers that happen to be integers don't change the strategy
+	for _, test := range []struct {
+		f        protocol.Frequency
+		strategy Strategy
+		want     Strategy
+	}{
+		{10 * protocol.Megahertz, FineMultiSynth, FineMultiSynth},
+		{10 * protocol.Megahertz, Split, Split},
+		{25 * protocol.Megahertz, Split, Split},
+		{7 * protocol.Megahertz, Split, Split},
+		{10 * protocol.Megahertz, Auto, FineMultiSynth},
+		{25 * protocol.Megahertz, Auto, FineMultiSynth},
+	} {
+		c, err := NewStrategy(25*protocol.Megahertz, test.f, test.strategy)
+		if err != nil {
+			t.Fatalf("%v %v: unexpected error: %v", test.f, test.strategy, err)
+		}
+		if c.Strategy() != test.want {
+			t.Errorf("%v %v: got %v (%d + %d/%d, %d + %d/%d)", test.f, test.strategy, c.Strategy(),
+				c.a0, c.b0, c.c0, c.a1, c.b1, c.c1)
+		}
+		ms := float64(c.a1) + float64(c.b1)/float64(c.c1)
+		want := protocol.FromHz(c.f.Hz() / ms * fareyGap(uint64(c.b1), uint64(c.c1), maxDenominator))
+		if c.Resolution() != want {
+			t.Errorf("%v %v: resolution %v, want %v", test.f, test.strategy, c.Resolution(), want)
+		}
+	}
+
+	// a crystal that isn't a round number forces the PLL to be fractional
+	c, err := NewStrategy(25_000_123_400*protocol.MilliHertz, 14_097_100*protocol.Hertz, Split)
+	if err != nil || c.Strategy() != Split {
+		t.Errorf("got %v, %v", c.Strategy(), err)
+	}
+}
+
+func Test_strategyErrors(t *testing.T) {
+	for _, test := range []struct {
+		f0, f    protocol.Frequency
+		strategy Strategy
+	}{
+		{25 * protocol.Megahertz, 144_490_500 * protocol.Hertz, FineMultiSynth},
+		{25 * protocol.Megahertz, 250 * protocol.Megahertz, FinePLL},
+		{5 * protocol.Megahertz, 10 * protocol.Megahertz, Auto},
+		{25 * protocol.Megahertz, 10 * protocol.Megahertz, Strategy(7)},
+	} {
+		if _, err := NewStrategy(test.f0, test.f, test.strategy); err == nil {
+			t.Errorf("%v %v: expected error", test.f, test.strategy)
+		}
+	}
+}
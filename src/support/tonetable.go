@@ -0,0 +1,182 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"wspr/src/protocol"
+)
+
+/*
+ToneEntry holds the register values for one frequency in a ToneTable.
+Registers holds the 8 PLL A registers followed by the 8 MultiSynth registers.
+Bit i of Changed is set if Registers[i] differs from the previous entry so
+only those registers need to be written.
+*/
+type ToneEntry struct {
+	Offset    time.Duration      // time from the start of the table
+	Frequency protocol.Frequency // frequency actually produced
+	Registers [16]byte
+	Changed   uint16
+}
+
+/*
+ToneTable holds precomputed Si5351 register values for a whole transmission
+so that the transmit loop only has to copy bytes to the chip at the right
+times. Output is the MultiSynth being driven from PLL A.
+
+Setup holds the CLKn control register for the output and the PLL A integer
+mode bit, which must be written before the first entry. The integer modes are
+only used if every entry allows them so these never change during the
+transmission. A fractional divider that happens to be an integer works fine
+in fractional mode.
+*/
+type ToneTable struct {
+	Start   time.Time
+	Output  int
+	Setup   []Register
+	Entries []ToneEntry
+}
+
+/*
+NewToneTable computes the Si5351 settings for every point in a frequency
+schedule such as that returned by protocol.Schedule.Points or Shaped. The
+parameters f0 and pll are as for New, with a zero pll meaning pick one.
+Points with the same register values as the point before are dropped so a
+shaped schedule with long flat sections stays compact. The first entry has
+all registers marked as changed.
+*/
+func NewToneTable(f0, pll protocol.Frequency, output int, points []protocol.Point) (ToneTable, error) {
+	return newToneTable(output, points, func(f protocol.Frequency) (Si5351Config, error) {
+		return New(f0, pll, f)
+	})
+}
+
+/*
+NewToneTableStrategy is like NewToneTable, but sets up every point with a
+particular strategy as NewStrategy does. Using the strategy of the carrier
+setting keeps the table consistent with it.
+*/
+func NewToneTableStrategy(f0 protocol.Frequency, strategy Strategy, output int, points []protocol.Point) (ToneTable, error) {
+	return newToneTable(output, points, func(f protocol.Frequency) (Si5351Config, error) {
+		return NewStrategy(f0, f, strategy)
+	})
+}
+
+// newToneTable builds a table using config to compute the settings for each point
+func newToneTable(output int, points []protocol.Point, config func(f protocol.Frequency) (Si5351Config, error)) (ToneTable, error) {
+	if output < 0 || output > 5 {
+		return ToneTable{}, fmt.Errorf("ToneTable: invalid output %d", output)
+	}
+	if len(points) == 0 {
+		return ToneTable{}, errors.New("ToneTable: no points")
+	}
+	t := ToneTable{Start: points[0].At, Output: output}
+	var prev [16]byte
+	var lastF protocol.Frequency
+	msInt, pllInt := true, true
+	for i, p := range points {
+		if i > 0 && p.At.Before(points[i-1].At) {
+			return ToneTable{}, fmt.Errorf("ToneTable: point %d is out of order", i)
+		}
+		if i > 0 && p.Frequency == lastF {
+			continue
+		}
+		lastF = p.Frequency
+		c, err := config(p.Frequency)
+		if err != nil {
+			return ToneTable{}, fmt.Errorf("ToneTable: point %d: %w", i, err)
+		}
+		e := ToneEntry{
+			Offset:    p.At.Sub(t.Start),
+			Frequency: c.Frequency(),
+			Registers: c.registers(),
+		}
+		for j := range e.Registers {
+			if i == 0 || e.Registers[j] != prev[j] {
+				e.Changed |= 1 << j
+			}
+		}
+		if e.Changed == 0 {
+			continue
+		}
+		prev = e.Registers
+		t.Entries = append(t.Entries, e)
+		msInt = msInt && c.msInteger()
+		pllInt = pllInt && c.b0 == 0
+	}
+
+	control := byte(clkSrcMS | clkDrive8mA)
+	if msInt {
+		control |= clkMSInt
+	}
+	fb := byte(0)
+	if pllInt {
+		fb = pllFBInt
+	}
+	t.Setup = []Register{
+		{Address: uint8(regClk0Control + output), Value: control, Mask: 0xff},
+		{Address: regPLLAInt, Value: fb, Mask: pllFBInt},
+	}
+	return t, nil
+}
+
+/*
+Writes calls write for each run of consecutive changed registers in entry i
+with the address of the first register and the new values. The data slice
+points into the table so nothing is allocated. Setup has to be written
+before the first entry.
+*/
+func (t *ToneTable) Writes(i int, write func(address uint8, data []byte) error) error {
+	e := &t.Entries[i]
+	for j := 0; j < len(e.Registers); {
+		if e.Changed&(1<<j) == 0 {
+			j++
+			continue
+		}
+		// the PLL and MultiSynth registers aren't adjacent so runs stop at 8
+		k := j + 1
+		for k < len(e.Registers) && k != 8 && e.Changed&(1<<k) != 0 {
+			k++
+		}
+		if err := write(t.address(j), e.Registers[j:k]); err != nil {
+			return err
+		}
+		j = k
+	}
+	return nil
+}
+
+// address converts an index into ToneEntry.Registers into a register address
+func (t *ToneTable) address(j int) uint8 {
+	if j < 8 {
+		return uint8(regPLLA + j)
+	}
+	return uint8(regMS0 + 8*t.Output + j - 8)
+}
+
+// registers returns the PLL and MultiSynth registers for a configuration
+func (c Si5351Config) registers() [16]byte {
+	var r [16]byte
+	pll := c.pllBlock()
+	ms := c.msBlock()
+	copy(r[:8], pll[:])
+	copy(r[8:], ms[:])
+	return r
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2025 Ted Dunning
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package support
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"wspr/src/protocol"
+)
+
+func testPoints(t *testing.T, dial protocol.Frequency) []protocol.Point {
+	symbols, err := protocol.WsprMessage("K1ABC", "FN42", 37)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := protocol.NewSchedule(symbols, dial, 1500*protocol.Hertz, protocol.WSPR2, time.Date(2025, 3, 1, 10, 0, 1, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s.Points()
+}
+
+func Test_toneTable(t *testing.T) {
+	for _, test := range []struct {
+		dial  protocol.Frequency
+		fixed uint16 // registers that should never change after the first entry
+		setup [2]byte
+	}{
+		{14_095_600 * protocol.Hertz, 0x00ff, [2]byte{0x0f, 0x40}},  // fixed PLL, fine tuned MultiSynth
+		{144_489_000 * protocol.Hertz, 0xff00, [2]byte{0x4f, 0x00}}, // fixed MultiSynth, fine tuned PLL
+	} {
+		points := testPoints(t, test.dial)
+		table, err := NewToneTable(25*protocol.Megahertz, 0, 2, points)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(table.Entries) < 50 || len(table.Entries) >= len(points) {
+			t.Errorf("%v: %d entries for %d points", test.dial, len(table.Entries), len(points))
+		}
+		if table.Entries[0].Changed != 0xffff || table.Entries[0].Offset != 0 {
+			t.Errorf("first entry %+v", table.Entries[0])
+		}
+		want := []Register{{18, test.setup[0], 0xff}, {regPLLAInt, test.setup[1], pllFBInt}}
+		if !reflect.DeepEqual(table.Setup, want) {
+			t.Errorf("%v: setup %+v, want %+v", test.dial, table.Setup, want)
+		}
+
+		// apply the writes to a copy of the registers and compare
+		var regs [256]byte
+		j := 0
+		for i, e := range table.Entries {
+			if i > 0 && (e.Changed == 0 || e.Changed&test.fixed != 0) {
+				t.Errorf("%v: entry %d changed %04x", test.dial, i, e.Changed)
+			}
+			// find the point this entry came from
+			for points[j].At.Sub(table.Start) != e.Offset {
+				j++
+			}
+			if abs(e.Frequency-points[j].Frequency) > points[j].Frequency.MulDiv(1, 1e9) {
+				t.Errorf("%v: entry %d is %v, want %v", test.dial, i, e.Frequency, points[j].Frequency)
+			}
+			err := table.Writes(i, func(address uint8, data []byte) error {
+				if int(address) < regPLLA || int(address)+len(data) > regMS0+8*6 {
+					t.Fatalf("write to %d of %d bytes", address, len(data))
+				}
+				copy(regs[address:], data)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if [8]byte(regs[regPLLA:regPLLA+8]) != [8]byte(e.Registers[:8]) ||
+				[8]byte(regs[regMS0+16:regMS0+24]) != [8]byte(e.Registers[8:]) {
+				t.Fatalf("%v: registers don't match after entry %d", test.dial, i)
+			}
+		}
+	}
+}
+
+func Test_toneTableModes(t *testing.T) {
+	// an integer MultiSynth followed by a fractional one can't use integer mode
+	start := time.Date(2025, 3, 1, 10, 0, 1, 0, time.UTC)
+	points := []protocol.Point{
+		{At: start, Frequency: 10 * protocol.Megahertz},
+		{At: start.Add(time.Second), Frequency: 10*protocol.Megahertz + protocol.Hertz},
+	}
+	table, err := NewToneTable(25*protocol.Megahertz, 0, 0, points)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first, _ := New(25*protocol.Megahertz, 0, 10*protocol.Megahertz)
+	if !first.msInteger() || len(table.Entries) != 2 {
+		t.Fatalf("expected an integer first entry in %+v", table.Entries)
+	}
+	want := []Register{{16, 0x0f, 0xff}, {regPLLAInt, pllFBInt, pllFBInt}}
+	if !reflect.DeepEqual(table.Setup, want) {
+		t.Errorf("setup %+v, want %+v", table.Setup, want)
+	}
+}
+
+func Test_toneTableErrors(t *testing.T) {
+	points := testPoints(t, 14_095_600*protocol.Hertz)
+	if _, err := NewToneTable(25*protocol.Megahertz, 0, 6, points); err == nil {
+		t.Errorf("expected error for bad output")
+	}
+	if _, err := NewToneTable(25*protocol.Megahertz, 0, 0, nil); err == nil {
+		t.Errorf("expected error for no points")
+	}
+	points[3], points[4] = points[4], points[3]
+	if _, err := NewToneTable(25*protocol.Megahertz, 0, 0, points); err == nil {
+		t.Errorf("expected error for points out of order")
+	}
+	if _, err := NewToneTable(25*protocol.Megahertz, 0, 0, []protocol.Point{{Frequency: 250 * protocol.Megahertz}}); err == nil {
+		t.Errorf("expected error for impossible frequency")
+	}
+}